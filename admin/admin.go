@@ -0,0 +1,360 @@
+// Package admin serves an HTTP endpoint to inspect and control the PubSubs
+// of a running process.
+//
+// Every PubSub is registered under a topic name. The endpoint lists the
+// topics with their stats and subscriptions, peeks at the last values of a
+// topic, pauses and resumes topics and subscriptions, and unsubscribes
+// stuck subscriptions. Responses are JSON, or HTML for browsers.
+//
+//	GET  /                                          topics
+//	GET  /topics/{topic}                            a topic
+//	GET  /topics/{topic}/peek?n=10                  the last n values
+//	POST /topics/{topic}/pause                      pause every subscription, including new ones
+//	POST /topics/{topic}/resume                     resume every subscription
+//	POST /topics/{topic}/subscriptions/{id}/pause
+//	POST /topics/{topic}/subscriptions/{id}/resume
+//	POST /topics/{topic}/subscriptions/{id}/unsubscribe
+//
+// The Handler does no authentication, it should only be served on an
+// internal address or behind a handler that does. It rejects POSTs made by
+// browsers from other origins.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/benburkert/pubsub"
+)
+
+// Topic is the state of a registered PubSub.
+type Topic struct {
+	Name          string         `json:"name"`
+	Paused        bool           `json:"paused"`
+	Stats         pubsub.Stats   `json:"stats"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// Subscription is the state of an active subscription.
+type Subscription struct {
+	ID        uint64 `json:"id"`
+	Name      string `json:"name,omitempty"`
+	Lag       int64  `json:"lag"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Paused    bool   `json:"paused"`
+}
+
+// Item is a value returned by peek. Value is the JSON encoding of the
+// value, empty if it has none, and Text is its default format.
+type Item struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	Text  string          `json:"text"`
+}
+
+// Handler is an http.Handler for the PubSubs registered with it.
+type Handler struct {
+	maxPeek int
+
+	mu     sync.RWMutex
+	topics map[string]*pubsub.PubSub
+
+	mux *http.ServeMux
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithMaxPeek sets the largest number of values returned by a peek. It
+// defaults to 100.
+func WithMaxPeek(n int) Option {
+	return func(h *Handler) {
+		h.maxPeek = n
+	}
+}
+
+// New returns a Handler without topics.
+func New(opts ...Option) *Handler {
+	h := &Handler{
+		maxPeek: 100,
+		topics:  make(map[string]*pubsub.PubSub),
+		mux:     http.NewServeMux(),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.mux.HandleFunc("GET /{$}", h.serveTopics)
+	h.mux.HandleFunc("GET /topics/{topic}", h.serveTopic)
+	h.mux.HandleFunc("GET /topics/{topic}/peek", h.servePeek)
+	h.mux.HandleFunc("POST /topics/{topic}/{action}", h.serveTopicAction)
+	h.mux.HandleFunc("POST /topics/{topic}/subscriptions/{id}/{action}", h.serveSubAction)
+	return h
+}
+
+// Register adds ps under topic, replacing the PubSub already registered
+// under it.
+func (h *Handler) Register(topic string, ps *pubsub.PubSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.topics[topic] = ps
+}
+
+// Unregister removes topic.
+func (h *Handler) Unregister(topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.topics, topic)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && crossOrigin(r) {
+		writeError(w, http.StatusForbidden, "cross-origin request")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// crossOrigin reports whether a browser sent r from another origin.
+// Requests without Sec-Fetch-Site or Origin headers are not from browsers.
+func crossOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "":
+	case "same-origin", "none":
+		return false
+	default:
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+func (h *Handler) serveTopics(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	names := make([]string, 0, len(h.topics))
+	for name := range h.topics {
+		names = append(names, name)
+	}
+	h.mu.RUnlock()
+	sort.Strings(names)
+
+	topics := make([]Topic, 0, len(names))
+	for _, name := range names {
+		if t, ok := h.topic(name); ok {
+			topics = append(topics, t)
+		}
+	}
+
+	if wantsHTML(r) {
+		render(w, topicsTmpl, topics)
+		return
+	}
+	writeJSON(w, http.StatusOK, topics)
+}
+
+func (h *Handler) serveTopic(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.topic(r.PathValue("topic"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown topic")
+		return
+	}
+
+	if wantsHTML(r) {
+		render(w, topicTmpl, t)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (h *Handler) servePeek(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("topic")
+	ps, ok := h.lookup(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown topic")
+		return
+	}
+
+	n := 10
+	if s := r.URL.Query().Get("n"); s != "" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid n")
+			return
+		}
+	}
+	if n > h.maxPeek {
+		n = h.maxPeek
+	}
+
+	vs := ps.Peek(n)
+	items := make([]Item, len(vs))
+	for i, v := range vs {
+		items[i] = newItem(v)
+	}
+
+	if wantsHTML(r) {
+		render(w, peekTmpl, struct {
+			Name  string
+			Items []Item
+		}{name, items})
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (h *Handler) serveTopicAction(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("topic")
+	ps, ok := h.lookup(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown topic")
+		return
+	}
+
+	switch r.PathValue("action") {
+	case "pause":
+		ps.Pause()
+	case "resume":
+		ps.Resume()
+	default:
+		writeError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+
+	h.afterAction(w, r, name, "../"+url.PathEscape(name))
+}
+
+func (h *Handler) serveSubAction(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("topic")
+	ps, ok := h.lookup(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown topic")
+		return
+	}
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid subscription id")
+		return
+	}
+
+	var sub *pubsub.Subscription
+	for _, s := range ps.Subscriptions() {
+		if s.ID() == id {
+			sub = s
+			break
+		}
+	}
+	if sub == nil {
+		writeError(w, http.StatusNotFound, "unknown subscription")
+		return
+	}
+
+	switch r.PathValue("action") {
+	case "pause":
+		sub.Pause()
+	case "resume":
+		sub.Resume()
+	case "unsubscribe":
+		// a stuck subscription would never read an unsubscribe queued
+		// behind the values it holds back.
+		sub.UnsubscribeNow()
+	default:
+		writeError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+
+	h.afterAction(w, r, name, "../../../"+url.PathEscape(name))
+}
+
+// afterAction responds to a successful action with the topic, or redirects
+// a browser to the topic page at the relative path back.
+func (h *Handler) afterAction(w http.ResponseWriter, r *http.Request, name, back string) {
+	if wantsHTML(r) {
+		w.Header().Set("Location", back)
+		w.WriteHeader(http.StatusSeeOther)
+		return
+	}
+
+	t, ok := h.topic(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown topic")
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (h *Handler) lookup(name string) (*pubsub.PubSub, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ps, ok := h.topics[name]
+	return ps, ok
+}
+
+func (h *Handler) topic(name string) (Topic, bool) {
+	ps, ok := h.lookup(name)
+	if !ok {
+		return Topic{}, false
+	}
+
+	t := Topic{
+		Name:          name,
+		Paused:        ps.Paused(),
+		Stats:         ps.Stats(),
+		Subscriptions: []Subscription{},
+	}
+	for _, s := range ps.Subscriptions() {
+		t.Subscriptions = append(t.Subscriptions, Subscription{
+			ID:        s.ID(),
+			Name:      s.Name(),
+			Lag:       s.Lag(),
+			Delivered: s.Delivered(),
+			Dropped:   s.Dropped(),
+			Paused:    s.Paused(),
+		})
+	}
+	return t, true
+}
+
+func newItem(v interface{}) Item {
+	it := Item{Text: fmt.Sprintf("%v", v)}
+	if data, err := json.Marshal(v); err == nil {
+		it.Value = data
+	}
+	return it
+}
+
+// wantsHTML reports whether r asks for the HTML view, with a format=html
+// query parameter or a browser Accept header.
+func wantsHTML(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "html"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{msg})
+}
@@ -0,0 +1,165 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benburkert/pubsub"
+)
+
+func do(t *testing.T, h http.Handler, method, target, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ps, err := pubsub.New(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	sub, err := ps.SubFunc(func(interface{}) {}, pubsub.WithName("worker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(WithMaxPeek(2))
+	h.Register("events", ps)
+
+	var topics []Topic
+	decode(t, do(t, h, "GET", "/", ""), &topics)
+	if len(topics) != 1 || topics[0].Name != "events" {
+		t.Fatalf("want topic events, got %+v", topics)
+	}
+	subs := topics[0].Subscriptions
+	if len(subs) != 1 || subs[0].ID != sub.ID() || subs[0].Name != "worker" {
+		t.Fatalf("want subscription %d named worker, got %+v", sub.ID(), subs)
+	}
+
+	ps.PubSlice([]interface{}{"A", "B", "C"})
+
+	var items []Item
+	decode(t, do(t, h, "GET", "/topics/events/peek?n=5", ""), &items)
+	if len(items) != 2 || items[0].Text != "B" || string(items[1].Value) != `"C"` {
+		t.Errorf("want peek of B and C, got %+v", items)
+	}
+
+	var topic Topic
+	decode(t, do(t, h, "POST", "/topics/events/pause", ""), &topic)
+	if !topic.Paused || !topic.Subscriptions[0].Paused || !sub.Paused() {
+		t.Errorf("want paused topic, got %+v", topic)
+	}
+	sub2, err := ps.SubFunc(func(interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sub2.Paused() {
+		t.Error("want new subscription of a paused topic paused")
+	}
+	decode(t, do(t, h, "POST", "/topics/events/resume", ""), &topic)
+	if topic.Paused || sub.Paused() || sub2.Paused() {
+		t.Errorf("want resumed topic, got %+v", topic)
+	}
+	sub2.Unsubscribe()
+	<-sub2.Done()
+
+	decode(t, do(t, h, "POST", "/topics/events/subscriptions/1/unsubscribe", ""), &topic)
+	<-sub.Done()
+	if len(ps.Subscriptions()) != 0 {
+		t.Error("want subscription unsubscribed")
+	}
+
+	for _, target := range []string{"/topics/nope", "/topics/events/subscriptions/1/pause"} {
+		method := "GET"
+		if strings.HasSuffix(target, "pause") {
+			method = "POST"
+		}
+		if rec := do(t, h, method, target, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: want status %d, got %d", method, target, http.StatusNotFound, rec.Code)
+		}
+	}
+
+	for _, hdr := range []struct{ key, value string }{
+		{"Sec-Fetch-Site", "cross-site"},
+		{"Origin", "http://evil.example"},
+	} {
+		req := httptest.NewRequest("POST", "/topics/events/pause", nil)
+		req.Header.Set(hdr.key, hdr.value)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || ps.Paused() {
+			t.Errorf("%s: %s: want status %d, got %d", hdr.key, hdr.value, http.StatusForbidden, rec.Code)
+		}
+	}
+
+	h.Unregister("events")
+	decode(t, do(t, h, "GET", "/", ""), &topics)
+	if len(topics) != 0 {
+		t.Errorf("want no topics, got %+v", topics)
+	}
+}
+
+func TestHandlerHTML(t *testing.T) {
+	ps, err := pubsub.New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	if _, err := ps.SubFunc(func(interface{}) {}); err != nil {
+		t.Fatal(err)
+	}
+	ps.Pub("<value>")
+
+	h := New()
+	h.Register("events", ps)
+
+	const accept = "text/html,application/xhtml+xml"
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/", `href="topics/events"`},
+		{"/topics/events", `action="events/subscriptions/1/unsubscribe"`},
+		{"/topics/events/peek", "&lt;value&gt;"},
+	}
+	for _, test := range tests {
+		rec := do(t, h, "GET", test.target, accept)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: want status %d, got %d", test.target, http.StatusOK, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("GET %s: want html, got %q", test.target, ct)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, test.want) {
+			t.Errorf("GET %s: want body to contain %q, got:\n%s", test.target, test.want, body)
+		}
+	}
+
+	rec := do(t, h, "POST", "/topics/events/subscriptions/1/pause", accept)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "../../../events" {
+		t.Errorf("want redirect to topic, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
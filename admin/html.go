@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+)
+
+const layout = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pubsub admin</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.75em; text-align: left; }
+form { display: inline; }
+</style>
+</head>
+<body>
+{{template "content" .}}
+</body>
+</html>
+`
+
+var (
+	topicsTmpl = newTemplate(`{{define "content"}}
+<h1>Topics</h1>
+<table>
+<tr><th>topic</th><th>subscriptions</th><th>expired</th><th>duplicates</th><th>paused</th></tr>
+{{range .}}<tr>
+<td><a href="topics/{{.Name}}">{{.Name}}</a></td>
+<td>{{len .Subscriptions}}</td>
+<td>{{.Stats.Expired}}</td>
+<td>{{.Stats.Duplicates}}</td>
+<td>{{.Paused}}</td>
+</tr>
+{{end}}</table>
+{{end}}`)
+
+	topicTmpl = newTemplate(`{{define "content"}}
+{{$topic := .Name}}
+<p><a href="../">topics</a></p>
+<h1>{{.Name}}</h1>
+<p>
+expired {{.Stats.Expired}}, duplicates {{.Stats.Duplicates}}, paused {{.Paused}}
+<form method="post" action="{{.Name}}/{{if .Paused}}resume{{else}}pause{{end}}"><button>{{if .Paused}}resume{{else}}pause{{end}}</button></form>
+<a href="{{.Name}}/peek?format=html">peek</a>
+</p>
+<h2>Subscriptions</h2>
+<table>
+<tr><th>id</th><th>name</th><th>lag</th><th>delivered</th><th>dropped</th><th>paused</th><th></th></tr>
+{{range .Subscriptions}}<tr>
+<td>{{.ID}}</td>
+<td>{{.Name}}</td>
+<td>{{.Lag}}</td>
+<td>{{.Delivered}}</td>
+<td>{{.Dropped}}</td>
+<td>{{.Paused}}</td>
+<td>
+<form method="post" action="{{$topic}}/subscriptions/{{.ID}}/{{if .Paused}}resume{{else}}pause{{end}}"><button>{{if .Paused}}resume{{else}}pause{{end}}</button></form>
+<form method="post" action="{{$topic}}/subscriptions/{{.ID}}/unsubscribe"><button>unsubscribe</button></form>
+</td>
+</tr>
+{{end}}</table>
+{{end}}`)
+
+	peekTmpl = newTemplate(`{{define "content"}}
+<p><a href="../{{.Name}}">{{.Name}}</a></p>
+<h1>Last values of {{.Name}}</h1>
+<ol>
+{{range .Items}}<li><code>{{.Text}}</code></li>
+{{end}}</ol>
+{{end}}`)
+)
+
+func newTemplate(content string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Parse(layout)).Parse(content))
+}
+
+func render(w http.ResponseWriter, t *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
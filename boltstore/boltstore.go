@@ -0,0 +1,60 @@
+//go:build !js && !wasip1
+
+// Package boltstore implements a pubsub.OffsetStore on top of bbolt.
+//
+// Offsets are sequence numbers of the ring, which start over in a new
+// process. Committed offsets only resume subscriptions after a restart when
+// the PubSub is restored from a snapshot, see pubsub.PubSub.Snapshot and
+// pubsub.PubSub.Restore.
+package boltstore
+
+import (
+	"encoding/binary"
+
+	"go.etcd.io/bbolt"
+)
+
+// Store keeps committed offsets as keys of a bbolt bucket.
+type Store struct {
+	db     *bbolt.DB
+	bucket []byte
+}
+
+// New returns a Store that keeps offsets in bucket of db, creating the
+// bucket if needed.
+func New(db *bbolt.DB, bucket string) (*Store, error) {
+	s := &Store{
+		db:     db,
+		bucket: []byte(bucket),
+	}
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(s.bucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load returns the committed sequence number of name.
+func (s *Store) Load(name string) (seq int64, ok bool, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(s.bucket).Get([]byte(name))
+		if len(v) == 8 {
+			seq, ok = int64(binary.BigEndian.Uint64(v)), true
+		}
+		return nil
+	})
+	return seq, ok, err
+}
+
+// Store commits seq as the position of name.
+func (s *Store) Store(name string, seq int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(seq))
+		return tx.Bucket(s.bucket).Put([]byte(name), v)
+	})
+}
@@ -0,0 +1,47 @@
+//go:build !js && !wasip1
+
+package boltstore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/benburkert/pubsub"
+)
+
+var _ pubsub.OffsetStore = (*Store)(nil)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.db")
+
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(db, "offsets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := s.Load("a"); err != nil || ok {
+		t.Fatalf("want no offset, got ok=%t err=%v", ok, err)
+	}
+	if err := s.Store("a", 42); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if db, err = bbolt.Open(path, 0600, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if s, err = New(db, "offsets"); err != nil {
+		t.Fatal(err)
+	}
+	if seq, ok, err := s.Load("a"); err != nil || !ok || seq != 42 {
+		t.Errorf("want offset 42, got %d ok=%t err=%v", seq, ok, err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package bridge connects PubSub streams to byte streams.
+package bridge
+
+import (
+	"io"
+
+	"github.com/benburkert/pubsub"
+)
+
+// Reader is a Publisher that publishes every value read by a Decoder.
+type Reader struct {
+	dec Decoder
+
+	donec chan struct{}
+	err   error
+}
+
+// NewReader returns a Reader that publishes values read by dec.
+func NewReader(dec Decoder) *Reader {
+	return &Reader{
+		dec:   dec,
+		donec: make(chan struct{}),
+	}
+}
+
+// PublishTo starts publishing to ctx. It stops at the end of the stream, on
+// the first decode error, or when the PubSub is closed. A Decode call that
+// is blocked when the PubSub closes is abandoned, not interrupted.
+func (r *Reader) PublishTo(ctx *pubsub.Context) error {
+	vc, errc := make(chan interface{}), make(chan error, 1)
+	go func() {
+		for {
+			v, err := r.dec.Decode()
+			if err != nil {
+				errc <- err
+				return
+			}
+
+			select {
+			case vc <- v:
+			case <-ctx.Done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(r.donec)
+		defer ctx.Close()
+
+		for {
+			select {
+			case v := <-vc:
+				ctx.Buffer.Write(v)
+			case err := <-errc:
+				if err != io.EOF {
+					r.err = err
+				}
+				return
+			case <-ctx.Done:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Done is closed once the Reader stops publishing.
+func (r *Reader) Done() <-chan struct{} {
+	return r.donec
+}
+
+// Err waits for the Reader to stop and returns the decode error that
+// stopped it, or nil if the stream ended or the PubSub was closed.
+func (r *Reader) Err() error {
+	<-r.donec
+	return r.err
+}
+
+// Writer is a Subscriber that writes every value to an Encoder.
+type Writer struct {
+	enc Encoder
+
+	donec chan struct{}
+	err   error
+}
+
+// NewWriter returns a Writer that writes values with enc.
+func NewWriter(enc Encoder) *Writer {
+	return &Writer{
+		enc:   enc,
+		donec: make(chan struct{}),
+	}
+}
+
+// SubscribeTo starts writing values read from ctx. It stops on the first
+// encode error, when it is unsubscribed or when the PubSub is closed.
+func (w *Writer) SubscribeTo(ctx *pubsub.Context) error {
+	rfn := func(v interface{}) bool {
+		if _, ok := v.(pubsub.MarkerChan); ok {
+			if v != ctx.Done && v != ctx.Unsub {
+				return true
+			}
+		} else if w.err = w.enc.Encode(v); w.err == nil {
+			return true
+		}
+
+		close(w.donec)
+		ctx.Close()
+		return false
+	}
+
+	ctx.Track(ctx.Buffer.ReadTo(rfn))
+	return nil
+}
+
+// Done is closed once the Writer stops writing.
+func (w *Writer) Done() <-chan struct{} {
+	return w.donec
+}
+
+// Err waits for the Writer to stop and returns the encode error that
+// stopped it, or nil if the PubSub was closed.
+func (w *Writer) Err() error {
+	<-w.donec
+	return w.err
+}
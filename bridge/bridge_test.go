@@ -0,0 +1,189 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+)
+
+func TestReaderWriter(t *testing.T) {
+	ps, err := pubsub.New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	w := NewWriter(NewLineEncoder(out))
+	if _, err := ps.AddSubscriber(w); err != nil {
+		t.Fatal(err)
+	}
+
+	in := "one\ntwo\r\nthree\nfour\nfive"
+	r := NewReader(NewLineDecoder(strings.NewReader(in)))
+	if err := ps.AddPublisher(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	ps.Close()
+
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "one\ntwo\nthree\nfour\nfive\n", out.String(); want != got {
+		t.Errorf("want output %q, got %q", want, got)
+	}
+}
+
+func TestWriterError(t *testing.T) {
+	ps, err := pubsub.New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	w := NewWriter(NewLengthEncoder(io.Discard))
+	if _, err := ps.AddSubscriber(w); err != nil {
+		t.Fatal(err)
+	}
+
+	ps.Pub("ok")
+	ps.Pub(42)
+
+	if err := w.Err(); err == nil {
+		t.Error("want encode error for int value")
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	ps, err := pubsub.New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	r := NewReader(NewLengthDecoder(strings.NewReader("\x00\x00\x00\x05abc")))
+	if err := ps.AddPublisher(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Err(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("want error %q, got %q", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestLengthMaxFrameSize(t *testing.T) {
+	enc := NewLengthEncoder(io.Discard, WithMaxFrameSize(4))
+	if err := enc.Encode("abcd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode("abcde"); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("want error %q, got %v", ErrFrameTooLarge, err)
+	}
+
+	dec := NewLengthDecoder(strings.NewReader("\x00\x00\x00\x05abcde"), WithMaxFrameSize(4))
+	if _, err := dec.Decode(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("want error %q, got %v", ErrFrameTooLarge, err)
+	}
+
+	// the default limit rejects a huge length before allocating it.
+	dec = NewLengthDecoder(strings.NewReader("\xff\xff\xff\xff"))
+	if _, err := dec.Decode(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("want error %q, got %v", ErrFrameTooLarge, err)
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func TestEncodings(t *testing.T) {
+	gob.Register(point{})
+	codec.RegisterType(point{})
+
+	tests := []struct {
+		name   string
+		enc    func(io.Writer) Encoder
+		dec    func(io.Reader) Decoder
+		values []interface{}
+		want   []interface{}
+	}{
+		{
+			name:   "line",
+			enc:    NewLineEncoder,
+			dec:    NewLineDecoder,
+			values: []interface{}{"a", []byte("b"), 3},
+			want:   []interface{}{"a", "b", "3"},
+		},
+		{
+			name:   "length",
+			enc:    func(w io.Writer) Encoder { return NewLengthEncoder(w) },
+			dec:    func(r io.Reader) Decoder { return NewLengthDecoder(r) },
+			values: []interface{}{"a\nb", []byte{0, 1, 2}, ""},
+			want:   []interface{}{[]byte("a\nb"), []byte{0, 1, 2}, []byte{}},
+		},
+		{
+			name:   "json",
+			enc:    NewJSONEncoder,
+			dec:    NewJSONDecoder,
+			values: []interface{}{"a", 1, point{1, 2}},
+			want:   []interface{}{"a", 1.0, map[string]interface{}{"X": 1.0, "Y": 2.0}},
+		},
+		{
+			name: "typed",
+			enc: func(w io.Writer) Encoder {
+				return NewTypedEncoder(w, codec.NewTyped(codec.JSON))
+			},
+			dec: func(r io.Reader) Decoder {
+				return NewTypedDecoder(r, codec.NewTyped(codec.JSON))
+			},
+			values: []interface{}{"a", 1, point{1, 2}},
+			want:   []interface{}{"a", 1, point{1, 2}},
+		},
+		{
+			name:   "gob",
+			enc:    NewGobEncoder,
+			dec:    NewGobDecoder,
+			values: []interface{}{"a", 1, point{1, 2}},
+			want:   []interface{}{"a", 1, point{1, 2}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			enc := test.enc(buf)
+			for _, v := range test.values {
+				if err := enc.Encode(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := []interface{}{}
+			dec := test.dec(buf)
+			for {
+				v, err := dec.Decode()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, v)
+			}
+
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want decoded %#v, got %#v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package bridge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/benburkert/pubsub/codec"
+)
+
+// Decoder reads values from a byte stream. Decode returns io.EOF when the
+// stream ends cleanly.
+type Decoder interface {
+	Decode() (interface{}, error)
+}
+
+// Encoder writes values to a byte stream.
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+// NewLineDecoder returns a Decoder that reads newline delimited strings
+// from r. The line terminator is not included in decoded values.
+func NewLineDecoder(r io.Reader) Decoder {
+	return &lineDecoder{r: bufio.NewReader(r)}
+}
+
+type lineDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *lineDecoder) Decode() (interface{}, error) {
+	line, err := d.r.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, nil
+}
+
+// NewLineEncoder returns an Encoder that writes each value to w on its own
+// line. Strings and byte slices are written as is, other values are
+// formatted with fmt.
+func NewLineEncoder(w io.Writer) Encoder {
+	return &lineEncoder{w: w}
+}
+
+type lineEncoder struct {
+	w io.Writer
+}
+
+func (e *lineEncoder) Encode(v interface{}) error {
+	var err error
+	switch v := v.(type) {
+	case string:
+		_, err = io.WriteString(e.w, v+"\n")
+	case []byte:
+		_, err = e.w.Write(append(v[:len(v):len(v)], '\n'))
+	default:
+		_, err = fmt.Fprintln(e.w, v)
+	}
+	return err
+}
+
+// DefaultMaxFrameSize is the largest length prefixed frame that is encoded
+// or decoded unless WithMaxFrameSize is used.
+const DefaultMaxFrameSize = 16 << 20
+
+// ErrFrameTooLarge is returned for a length prefixed frame that is larger
+// than the maximum frame size.
+var ErrFrameTooLarge = errors.New("bridge: frame too large")
+
+// FrameOption configures the length prefixed frames of an Encoder or
+// Decoder.
+type FrameOption func(*frameConfig)
+
+// WithMaxFrameSize sets the largest frame in bytes. A size of 0 or less
+// allows every frame with a length that fits the 32-bit prefix.
+func WithMaxFrameSize(n int) FrameOption {
+	return func(cfg *frameConfig) {
+		cfg.max = int64(n)
+	}
+}
+
+type frameConfig struct {
+	max int64
+}
+
+func newFrameConfig(opts []FrameOption) frameConfig {
+	cfg := frameConfig{max: DefaultMaxFrameSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.max <= 0 || cfg.max > math.MaxUint32 {
+		cfg.max = math.MaxUint32
+	}
+	return cfg
+}
+
+func (cfg frameConfig) check(n int64) error {
+	if n > cfg.max {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrFrameTooLarge, n, cfg.max)
+	}
+	return nil
+}
+
+// NewLengthDecoder returns a Decoder that reads byte slices prefixed by
+// their length as a 32-bit big endian integer. Frames larger than the
+// maximum frame size fail with ErrFrameTooLarge.
+func NewLengthDecoder(r io.Reader, opts ...FrameOption) Decoder {
+	return &lengthDecoder{r: r, cfg: newFrameConfig(opts)}
+}
+
+type lengthDecoder struct {
+	r   io.Reader
+	cfg frameConfig
+	hdr [4]byte
+}
+
+func (d *lengthDecoder) Decode() (interface{}, error) {
+	if _, err := io.ReadFull(d.r, d.hdr[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(d.hdr[:])
+	if err := d.cfg.check(int64(n)); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// NewLengthEncoder returns an Encoder that writes byte slices and strings
+// prefixed by their length as a 32-bit big endian integer. Values larger
+// than the maximum frame size fail with ErrFrameTooLarge.
+func NewLengthEncoder(w io.Writer, opts ...FrameOption) Encoder {
+	return &lengthEncoder{w: w, cfg: newFrameConfig(opts)}
+}
+
+type lengthEncoder struct {
+	w   io.Writer
+	cfg frameConfig
+}
+
+func (e *lengthEncoder) Encode(v interface{}) error {
+	var b []byte
+	switch v := v.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("bridge: can not length encode %T", v)
+	}
+	if err := e.cfg.check(int64(len(b))); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	copy(buf[4:], b)
+
+	_, err := e.w.Write(buf)
+	return err
+}
+
+// NewJSONDecoder returns a Decoder that reads a stream of JSON values from
+// r. Values decode to the types used by json.Unmarshal for interface{}.
+func NewJSONDecoder(r io.Reader) Decoder {
+	return &jsonDecoder{d: json.NewDecoder(r)}
+}
+
+type jsonDecoder struct {
+	d *json.Decoder
+}
+
+func (d *jsonDecoder) Decode() (interface{}, error) {
+	var v interface{}
+	if err := d.d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewJSONEncoder returns an Encoder that writes values to w as newline
+// delimited JSON.
+func NewJSONEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+// NewGobDecoder returns a Decoder that reads a gob stream written by a gob
+// Encoder. Concrete types must be registered with gob.Register.
+func NewGobDecoder(r io.Reader) Decoder {
+	return &gobDecoder{d: gob.NewDecoder(r)}
+}
+
+type gobDecoder struct {
+	d *gob.Decoder
+}
+
+func (d *gobDecoder) Decode() (interface{}, error) {
+	var v interface{}
+	if err := d.d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewGobEncoder returns an Encoder that writes values to w as a gob stream.
+// Concrete types must be registered with gob.Register.
+func NewGobEncoder(w io.Writer) Encoder {
+	return &gobEncoder{e: gob.NewEncoder(w)}
+}
+
+type gobEncoder struct {
+	e *gob.Encoder
+}
+
+func (e *gobEncoder) Encode(v interface{}) error {
+	return e.e.Encode(&v)
+}
+
+// NewTypedDecoder returns a Decoder that reads length prefixed frames
+// written by a typed Encoder and decodes them with t.
+func NewTypedDecoder(r io.Reader, t *codec.Typed, opts ...FrameOption) Decoder {
+	return &typedDecoder{d: NewLengthDecoder(r, opts...), t: t}
+}
+
+type typedDecoder struct {
+	d Decoder
+	t *codec.Typed
+}
+
+func (d *typedDecoder) Decode() (interface{}, error) {
+	v, err := d.d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return d.t.Decode(v.([]byte))
+}
+
+// NewTypedEncoder returns an Encoder that writes values encoded with t as
+// length prefixed frames, preserving their registered type across
+// processes.
+func NewTypedEncoder(w io.Writer, t *codec.Typed, opts ...FrameOption) Encoder {
+	return &typedEncoder{e: NewLengthEncoder(w, opts...), t: t}
+}
+
+type typedEncoder struct {
+	e Encoder
+	t *codec.Typed
+}
+
+func (e *typedEncoder) Encode(v interface{}) error {
+	b, err := e.t.Encode(v)
+	if err != nil {
+		return err
+	}
+	return e.e.Encode(b)
+}
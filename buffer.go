@@ -3,6 +3,8 @@ package pubsub
 import (
 	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/benburkert/pubsub/cursor"
 )
@@ -10,20 +12,64 @@ import (
 type Marker byte
 
 const (
+	// Deprecated: empty slots are found by sequence number and are no
+	// longer filled with EmptyMarker.
 	EmptyMarker Marker = iota
 )
 
 type ReaderFunc func(interface{}) bool
 
+// seqReaderFunc is a ReaderFunc that is also passed the sequence number of
+// each value.
+type seqReaderFunc func(int64, interface{}) bool
+
+func (rfn ReaderFunc) withSeq() seqReaderFunc {
+	return func(_ int64, v interface{}) bool {
+		return rfn(v)
+	}
+}
+
 type Buffer struct {
 	mu   sync.RWMutex
 	data []interface{}
+	mask int64
+
+	// sequence number of the first write, non-zero after a restore
+	first int64
+
+	// expiry deadlines in unix nanoseconds, 0 if the value never expires,
+	// and a flag per slot recording that onExpire was called for it.
+	exps  []int64
+	expfs []uint32
+
+	clock    Clock
+	ttl      time.Duration
+	onExpire func(interface{})
+	expired  atomic.Uint64
+
+	// values expired by spill, passed to onExpire once the writer releases
+	// b.mu.
+	expiredq []interface{}
+
+	// rate limit of writes, nil if writes are not limited.
+	limit *limiter
+
+	// onStall is called when a writer has waited on a full buffer for
+	// stallAfter. readers record their goroutine ID if stallStacks is set.
+	stallAfter  time.Duration
+	onStall     func(start time.Time)
+	stallStacks bool
 
 	wcond   *sync.Cond
 	wcursor *cursor.Cursor
 
 	rcond    *sync.Cond
 	rcursors cursor.Slice
+
+	// readers that spill to an Overflow when they lag.
+	smu      sync.Mutex
+	spillers []*Reader
+	nspill   atomic.Int32
 }
 
 func NewBuffer(minSize, maxReaders int) *Buffer {
@@ -32,14 +78,14 @@ func NewBuffer(minSize, maxReaders int) *Buffer {
 
 	b := &Buffer{
 		data:     make([]interface{}, size),
+		mask:     int64(mask),
+		exps:     make([]int64, size),
+		expfs:    make([]uint32, size),
+		clock:    realClock{},
 		wcursor:  cursor.New(0, mask),
 		rcursors: cursor.MakeSlice(maxReaders, mask),
 	}
 
-	for i := range b.data {
-		b.data[i] = EmptyMarker
-	}
-
 	b.wcond = sync.NewCond(&b.mu)
 	b.rcond = sync.NewCond(b.mu.RLocker())
 	return b
@@ -51,7 +97,7 @@ func (b *Buffer) FullReadTo(rfn ReaderFunc) []interface{} {
 	c := b.getCursor() // reset in readTo
 	s := b.read(c)
 
-	go b.readTo(c, rfn)
+	go b.readTo(b.newReader(c), rfn.withSeq())
 	return s
 }
 
@@ -65,94 +111,454 @@ func (b *Buffer) Read() []interface{} {
 	return b.read(c)
 }
 
-func (b *Buffer) ReadTo(rfn ReaderFunc) {
+func (b *Buffer) ReadTo(rfn ReaderFunc) *Reader {
 	b.mu.RLock() // unlocked in readTo
 
-	go b.readTo(b.getCursor(), rfn)
+	r := b.newReader(b.getCursor())
+	go b.readTo(r, rfn.withSeq())
+	return r
+}
+
+// ReadFrom is like ReadTo but starts reading at sequence number seq. The
+// sequence is clamped to the values still held by the buffer.
+func (b *Buffer) ReadFrom(seq int64, rfn ReaderFunc) *Reader {
+	return b.readFrom(seq, rfn.withSeq())
+}
+
+// ReadFromSeq is like ReadFrom but also passes rfn the sequence number of
+// each value, for readers that resume from the last sequence they saw.
+func (b *Buffer) ReadFromSeq(seq int64, rfn func(int64, interface{}) bool) *Reader {
+	return b.readFrom(seq, rfn)
+}
+
+// ReadSpill is like ReadTo but never holds back writers for long. Once the
+// reader lags more than threshold values behind, writers move its pending
+// values to o. The reader reads them back from o before it continues with
+// the values in the buffer. The threshold is capped to the buffer size.
+func (b *Buffer) ReadSpill(threshold int, o Overflow, rfn ReaderFunc) *Reader {
+	return b.startReader(math.MaxInt64, nil, b.newSpillConfig(threshold, o), rfn.withSeq())
 }
 
+func (b *Buffer) readFrom(seq int64, rfn seqReaderFunc) *Reader {
+	return b.startReader(seq, nil, nil, rfn)
+}
+
+// startReader starts a reader at seq. If acks is not nil, the reader's
+// cursor only moves past a value once it, and every value before it, is
+// acknowledged with acks.ack. Values can be acknowledged in any order. If
+// spill is not nil, the reader spills when it lags.
+func (b *Buffer) startReader(seq int64, acks *ackSet, spill *spillConfig, rfn seqReaderFunc) *Reader {
+	b.mu.RLock() // unlocked in readTo
+
+	if oseq := b.oldest(); seq < oseq {
+		seq = oseq
+	}
+	if wseq := b.wcursor.Seq(); seq > wseq {
+		seq = wseq
+	}
+
+	r := b.newReader(b.rcursors.Alloc(seq))
+	if acks != nil {
+		acks.init(r, len(b.data))
+	}
+	if spill != nil {
+		r.spill = spill
+		b.addSpiller(r)
+	}
+
+	go b.readTo(r, rfn)
+	return r
+}
+
+func (b *Buffer) newSpillConfig(threshold int, o Overflow) *spillConfig {
+	if max := len(b.data) - 1; threshold > max {
+		threshold = max
+	}
+	if threshold < 0 {
+		threshold = 0
+	}
+	if o == nil {
+		o = NewMemoryOverflow()
+	}
+	return &spillConfig{threshold: int64(threshold), overflow: o}
+}
+
+// Write writes v. If the buffer is rate limited, Write waits for a token,
+// or drops v if the limit rejects values over it.
 func (b *Buffer) Write(v interface{}) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	if b.admit(v) == nil {
+		b.put(b.ttl, v)
+	}
+}
 
-	b.write(v)
+// WriteLimited is like Write but returns ErrRateLimited instead of dropping
+// v when the rate limit rejects it.
+func (b *Buffer) WriteLimited(v interface{}) error {
+	if err := b.admit(v); err != nil {
+		return err
+	}
+	b.put(b.ttl, v)
+	return nil
 }
 
 func (b *Buffer) WriteSlice(vs []interface{}) {
+	if b.admit(vs...) == nil {
+		b.put(b.ttl, vs...)
+	}
+}
+
+// WriteTTL writes v with a time to live that overrides the default. Readers
+// skip v once it has been in the buffer for longer than ttl. A ttl of zero
+// means v never expires.
+func (b *Buffer) WriteTTL(v interface{}, ttl time.Duration) {
+	if b.admit(v) == nil {
+		b.put(ttl, v)
+	}
+}
+
+// Expired returns the number of values that readers skipped because they
+// expired.
+func (b *Buffer) Expired() uint64 {
+	return b.expired.Load()
+}
+
+// Seq returns the sequence number of the next write.
+func (b *Buffer) Seq() int64 {
+	return b.wcursor.Seq()
+}
+
+// restore fills an unused buffer with values starting at sequence number
+// seq. The newest values are kept if vs does not fit.
+func (b *Buffer) restore(seq int64, vs []interface{}, exps []int64) bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	for _, v := range vs {
-		b.write(v)
+	if b.wcursor.Seq() != b.first {
+		return false
+	}
+	for _, c := range b.rcursors {
+		if c.Seq() >= 0 {
+			return false
+		}
 	}
+
+	if n := len(vs) - len(b.data); n > 0 {
+		seq, vs, exps = seq+int64(n), vs[n:], exps[n:]
+	}
+
+	b.first = seq
+	b.wcursor = cursor.New(seq, int(b.mask))
+	for i, v := range vs {
+		pos := b.wcursor.Pos()
+		b.data[pos] = v
+		b.exps[pos] = exps[i]
+		b.wcursor.Inc()
+	}
+	return true
+}
+
+// assumes b.mu RLock held. Returns the sequence number of the oldest value
+// held by the buffer.
+func (b *Buffer) oldest() int64 {
+	oseq := b.wcursor.Seq() - int64(len(b.data))
+	if oseq < b.first {
+		return b.first
+	}
+	return oseq
 }
 
 // assumes b.mu RLock held
 func (b *Buffer) getCursor() *cursor.Cursor {
-	return b.rcursors.Alloc(b.wcursor.Pos())
+	return b.rcursors.Alloc(b.wcursor.Seq())
 }
 
-// assumes b.mu Rlock held
-func (b *Buffer) read(c *cursor.Cursor) []interface{} {
-	rpos := c.Pos()
-	if b.data[rpos] == EmptyMarker {
-		s := make([]interface{}, rpos)
-		copy(s, b.data[:rpos])
-		return s
+// assumes b.mu RLock held
+func (b *Buffer) newReader(c *cursor.Cursor) *Reader {
+	r := &Reader{
+		b:     b,
+		c:     c,
+		donec: make(chan struct{}),
 	}
+	r.seq.Store(c.Seq())
+	return r
+}
 
-	size := int(len(b.data))
-	s := make([]interface{}, size)
-	copy(s[:(size-rpos)], b.data[rpos:])
-	copy(s[(size-rpos):], b.data[:rpos])
-	return s
+// assumes b.mu Rlock held
+func (b *Buffer) read(c *cursor.Cursor) []interface{} {
+	return b.readBefore(c.Seq())
 }
 
-// assumes b.mu RLock held
-func (b *Buffer) readBarrier(c *cursor.Cursor) bool {
-	return c.Pos() == b.wcursor.Pos()
+// readBefore returns the values held by the buffer before rseq. assumes
+// b.mu RLock held
+func (b *Buffer) readBefore(rseq int64) []interface{} {
+	n := rseq - b.oldest()
+
+	s := make([]interface{}, n)
+	k := copy(s, b.data[(rseq-n)&b.mask:])
+	copy(s[k:], b.data)
+	return s
 }
 
 // asumes b.mu RLock held
-func (b *Buffer) readTo(c *cursor.Cursor, rfn ReaderFunc) {
+func (b *Buffer) readTo(r *Reader, rfn seqReaderFunc) {
 	defer b.mu.RUnlock()
 	defer b.wcond.Signal()
-	defer c.Reset()
 
+	if b.stallStacks {
+		r.gid.Store(goroutineID())
+	}
+
+	rseq := r.c.Seq()
 	for {
-		for b.readBarrier(c) {
-			b.rcond.Wait()
+		for rseq == b.wcursor.Seq() && !r.undrained() && !r.interrupted() {
+			// interrupt only wakes waiting readers, so check again once
+			// waiting is set.
+			r.waiting.Store(true)
+			if !r.interrupted() {
+				b.rcond.Wait()
+			}
+			r.waiting.Store(false)
+		}
+		if m := r.intr.Swap(nil); m != nil && !rfn(rseq, *m) {
+			r.stop(rseq)
+			return
+		}
+		if !r.drain(rfn) {
+			r.stop(rseq)
+			return
 		}
+		if r.spill != nil {
+			// writers may have moved the cursor past spilled values.
+			rseq = r.c.Seq()
+		}
+
+		for wseq := b.wcursor.Seq(); rseq != wseq; rseq++ {
+			if r.interrupted() {
+				break
+			}
+
+			paused := r.paused.Load()
+			if paused && r.policy() == PauseBlock {
+				// release the read lock so writers are only held back
+				// by the position of the reader.
+				b.wcond.Signal()
+				b.mu.RUnlock()
+				r.waitResume()
+				b.mu.RLock()
+				break
+			}
+
+			// a spilling reader copies the value and moves its cursor
+			// first, so writers can spill the values after it while it
+			// is busy.
+			spill := r.spill != nil
+			pos := int(rseq & b.mask)
+			v := b.data[pos]
+			expired := b.isExpired(pos)
+			if spill {
+				r.c.Inc()
+				r.seq.Store(rseq + 1)
+			}
 
-		rpos, wpos := c.Pos(), b.wcursor.Pos()
-		for rpos != wpos {
-			if !rfn(b.data[rpos]) {
+			if expired {
+				b.expire(pos)
+				r.skipped.Add(1)
+				if r.acks != nil {
+					r.acks.ack(rseq)
+				}
+			} else if !r.read(rseq, v, paused, rfn) {
+				r.stop(rseq)
 				return
 			}
-			rpos = c.Inc()
+
+			if spill {
+				if r.c.Seq() != rseq+1 {
+					break // spilled while in rfn
+				}
+			} else if r.acks == nil {
+				r.c.Inc()
+				r.seq.Store(rseq + 1)
+			}
 		}
 		b.wcond.Signal()
 	}
 }
 
+// wakeWriters wakes a writer waiting for an acknowledged reader. It must be
+// called without b.mu held.
+func (b *Buffer) wakeWriters() {
+	// a writer checks the barrier and starts waiting while it holds the
+	// lock, so once the read lock is acquired it is either waiting or will
+	// see the moved cursor.
+	b.mu.RLock()
+	b.mu.RUnlock()
+
+	b.wcond.Signal()
+}
+
+// wakeReaders wakes readers waiting for a write.
+func (b *Buffer) wakeReaders() {
+	// a reader checks for work and starts waiting while it holds the read
+	// lock, so once the lock is acquired it is either waiting or will see
+	// the change.
+	b.mu.Lock()
+	b.mu.Unlock()
+
+	b.rcond.Broadcast()
+}
+
+// assumes b.mu RLock held
+func (b *Buffer) isExpired(pos int) bool {
+	exp := b.exps[pos]
+	return exp != 0 && b.clock.Now().UnixNano() >= exp
+}
+
+// assumes b.mu RLock held
+func (b *Buffer) expire(pos int) {
+	if b.markExpired(pos) && b.onExpire != nil {
+		b.onExpire(b.data[pos])
+	}
+}
+
+// markExpired counts the expired value at pos and reports whether it was
+// not counted before. Only the first reader to skip an expired value counts
+// it. assumes b.mu RLock held
+func (b *Buffer) markExpired(pos int) bool {
+	if !atomic.CompareAndSwapUint32(&b.expfs[pos], 0, 1) {
+		return false
+	}
+
+	b.expired.Add(1)
+	return true
+}
+
+// admit takes a rate limit token for every value in vs that is not a
+// marker. It may sleep, so it must be called without b.mu held.
+func (b *Buffer) admit(vs ...interface{}) error {
+	if b.limit == nil {
+		return nil
+	}
+
+	n := 0
+	for _, v := range vs {
+		if _, ok := v.(MarkerChan); !ok {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	return b.limit.take(n)
+}
+
+// put writes vs without taking rate limit tokens. The values expired by
+// spilling readers are passed to onExpire after b.mu is released.
+func (b *Buffer) put(ttl time.Duration, vs ...interface{}) {
+	b.mu.Lock()
+	for _, v := range vs {
+		b.write(v, ttl)
+	}
+	expired := b.expiredq
+	b.expiredq = nil
+	b.mu.Unlock()
+
+	for _, v := range expired {
+		b.onExpire(v)
+	}
+}
+
 // asumes b.mu Lock held
-func (b *Buffer) write(v interface{}) {
-	for b.writeBarrier() {
-		b.wcond.Wait()
+func (b *Buffer) write(v interface{}, ttl time.Duration) {
+	b.spill()
+	if b.writeBarrier() {
+		t := b.watchStall()
+		for b.writeBarrier() {
+			b.wcond.Wait()
+			b.spill()
+		}
+		if t != nil {
+			t.Stop()
+		}
+		// readers wake a single writer, pass the wakeup on so the other
+		// waiting writers recheck the barrier.
+		b.wcond.Signal()
+	}
+
+	var exp int64
+	if _, ok := v.(MarkerChan); !ok && ttl > 0 {
+		exp = b.clock.Now().Add(ttl).UnixNano()
 	}
 
 	wpos := b.wcursor.Pos()
 	b.data[wpos] = v
+	b.exps[wpos] = exp
+	atomic.StoreUint32(&b.expfs[wpos], 0)
 	b.wcursor.Inc()
 
 	b.rcond.Broadcast()
 }
 
-// assumes b.mu Lock held
+// spill moves the pending values of readers that lag more than their
+// threshold to their Overflow, and their cursors past them. It stops at a
+// value the Overflow does not accept. assumes b.mu Lock held
+func (b *Buffer) spill() {
+	if b.nspill.Load() == 0 {
+		return
+	}
+
+	b.smu.Lock()
+	defer b.smu.Unlock()
+
+	wseq := b.wcursor.Seq()
+	for _, r := range b.spillers {
+		rseq := r.c.Seq()
+		if rseq < 0 || wseq-rseq <= r.spill.threshold {
+			continue
+		}
+
+		for ; rseq != wseq; rseq++ {
+			pos := int(rseq & b.mask)
+			if b.isExpired(pos) {
+				if b.markExpired(pos) && b.onExpire != nil {
+					b.expiredq = append(b.expiredq, b.data[pos])
+				}
+				r.skipped.Add(1)
+			} else if r.spill.overflow.Push(rseq, b.data[pos]) != nil {
+				break
+			}
+			r.c.Inc()
+		}
+		r.seq.Store(rseq)
+	}
+}
+
+func (b *Buffer) addSpiller(r *Reader) {
+	b.smu.Lock()
+	defer b.smu.Unlock()
+
+	b.spillers = append(b.spillers, r)
+	b.nspill.Add(1)
+}
+
+func (b *Buffer) removeSpiller(r *Reader) {
+	b.smu.Lock()
+	defer b.smu.Unlock()
+
+	for i, sr := range b.spillers {
+		if sr == r {
+			b.spillers = append(b.spillers[:i], b.spillers[i+1:]...)
+			b.nspill.Add(-1)
+			return
+		}
+	}
+}
+
+// assumes b.mu Lock held. The ring is full when a reader is a full lap
+// behind the writer.
 func (b *Buffer) writeBarrier() bool {
-	npos := b.wcursor.Next()
+	wseq, size := b.wcursor.Seq(), int64(len(b.data))
 	for _, c := range b.rcursors {
-		if npos == c.Pos() {
+		if rseq := c.Seq(); rseq >= 0 && wseq-rseq >= size {
 			return true
 		}
 	}
@@ -5,6 +5,7 @@ import (
 	"runtime"
 	"sync"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -124,6 +125,111 @@ func TestBufferFullReadTo(t *testing.T) {
 
 }
 
+func TestBufferReadFrom(t *testing.T) {
+	buffer := NewBuffer(4, 1)
+	buffer.WriteSlice([]interface{}{"A", "B", "C", "D", "E", "F"})
+
+	got := []interface{}{}
+	r := buffer.ReadFrom(0, func(v interface{}) bool {
+		got = append(got, v)
+		return v != "G"
+	})
+	buffer.Write("G")
+
+	<-r.Done()
+	if want := []interface{}{"C", "D", "E", "F", "G"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want buffer read from %v, got %v", want, got)
+	}
+}
+
+func TestBufferTTL(t *testing.T) {
+	clock := newFakeClock()
+	buffer := NewBuffer(8, 1)
+	buffer.clock = clock
+
+	expired := []interface{}{}
+	buffer.onExpire = func(v interface{}) { expired = append(expired, v) }
+
+	donec := make(chan struct{})
+	got := []interface{}{}
+	var rfn ReaderFunc = func(v interface{}) bool {
+		if v == "gate" {
+			clock.Advance(2 * time.Second)
+			return true
+		}
+		got = append(got, v)
+		if v == "D" {
+			close(donec)
+			return false
+		}
+		return true
+	}
+	r := buffer.ReadTo(rfn)
+
+	// write everything at once so the reader sees the values only after
+	// they were all written.
+	buffer.mu.Lock()
+	buffer.write("gate", 0)
+	buffer.write("A", time.Second)
+	buffer.write("B", 0)
+	buffer.write("C", 3*time.Second)
+	buffer.write("D", 0)
+	buffer.mu.Unlock()
+	<-donec
+
+	if want := []interface{}{"B", "C", "D"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want buffer read to %v, got %v", want, got)
+	}
+	if want := []interface{}{"A"}; !reflect.DeepEqual(want, expired) {
+		t.Errorf("want expired %v, got %v", want, expired)
+	}
+	if n := buffer.Expired(); n != 1 {
+		t.Errorf("want expired count=1, got %d", n)
+	}
+
+	<-r.Done()
+	if n := r.Skipped(); n != 1 {
+		t.Errorf("want reader skipped=1, got %d", n)
+	}
+}
+
+func TestBufferCapacity(t *testing.T) {
+	buffer := NewBuffer(4, 1)
+
+	got := []interface{}{}
+	r := buffer.ReadTo(func(v interface{}) bool {
+		got = append(got, v)
+		return len(got) < 4
+	})
+
+	// hold the lock so the reader can not make progress
+	buffer.mu.Lock()
+	for _, v := range []interface{}{"A", "B", "C", "D"} {
+		if buffer.writeBarrier() {
+			t.Fatalf("want write of %v to not block", v)
+		}
+		buffer.write(v, 0)
+	}
+	if !buffer.writeBarrier() {
+		t.Error("want full buffer to block writes")
+	}
+	if lag := r.Lag(); lag != 4 {
+		t.Errorf("want reader lag=4, got %d", lag)
+	}
+	buffer.mu.Unlock()
+
+	<-r.Done()
+	if want := []interface{}{"A", "B", "C", "D"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want buffer read to %v, got %v", want, got)
+	}
+	if lag := r.Lag(); lag != 0 {
+		t.Errorf("want done reader lag=0, got %d", lag)
+	}
+	if seq := buffer.Seq(); seq != 4 {
+		t.Errorf("want buffer seq=4, got %d", seq)
+	}
+}
+
 func TestConcurrentReadTo(t *testing.T) {
 	n, m := 1024, runtime.NumCPU()-1
 	buffer := NewBuffer(n, m)
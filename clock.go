@@ -0,0 +1,43 @@
+package pubsub
+
+import "time"
+
+// Clock is the source of time for scheduled publishing. It can be replaced
+// with WithClock to make time dependent behavior deterministic in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// AfterFunc calls fn in its own goroutine after duration d.
+	AfterFunc(d time.Duration, fn func()) Timer
+}
+
+// Timer is a pending call created by Clock.AfterFunc.
+type Timer interface {
+	// Stop prevents the Timer from firing. It returns false if the timer
+	// has already fired or been stopped.
+	Stop() bool
+}
+
+// SystemClock is the Clock of the time package, used unless WithClock sets
+// another.
+var SystemClock Clock = realClock{}
+
+// Sleep blocks until d has passed on clock. It returns at once if d is not
+// positive.
+func Sleep(clock Clock, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	wakec := make(chan struct{})
+	clock.AfterFunc(d, func() { close(wakec) })
+	<-wakec
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return time.AfterFunc(d, fn)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benburkert/pubsub"
+)
+
+// benchResult is the outcome of a bench run.
+type benchResult struct {
+	values    int // published values
+	delivered int // values handed to subscribers
+	elapsed   time.Duration
+
+	// latencies from publish to delivery, sorted.
+	latencies []time.Duration
+}
+
+func bench(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	pubs := fs.Int("pubs", 1, "number of publishers")
+	subs := fs.Int("subs", 1, "number of subscribers")
+	size := fs.Int("size", 1024, "minimum ring buffer size")
+	n := fs.Int("n", 100000, "number of values each publisher publishes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *pubs < 1 || *subs < 1 || *n < 1 {
+		return errors.New("-pubs, -subs and -n must be > 0")
+	}
+
+	res, err := runBench(ctx, *pubs, *subs, *size, *n)
+	if err != nil {
+		return err
+	}
+
+	secs := res.elapsed.Seconds()
+	fmt.Fprintf(e.stdout, "%d publishers, %d subscribers, ring size %d\n", *pubs, *subs, *size)
+	fmt.Fprintf(e.stdout, "published %d values in %s: %.0f values/s published, %.0f values/s delivered\n",
+		res.values, res.elapsed.Round(time.Millisecond), float64(res.values)/secs, float64(res.delivered)/secs)
+	fmt.Fprintf(e.stdout, "latency p50 %s, p90 %s, p99 %s, p99.9 %s, max %s\n",
+		res.percentile(50), res.percentile(90), res.percentile(99), res.percentile(99.9), res.percentile(100))
+	return nil
+}
+
+// runBench publishes n timestamps from each of pubs publishers to a
+// PubSub with subs subscribers, and measures how long each value takes to
+// reach every subscriber.
+func runBench(ctx context.Context, pubs, subs, size, n int) (*benchResult, error) {
+	ps, err := pubsub.New(size, subs)
+	if err != nil {
+		return nil, err
+	}
+	defer ps.Close()
+
+	total := pubs * n
+	start := time.Now()
+
+	latencies := make([][]time.Duration, subs)
+	var wg sync.WaitGroup
+	wg.Add(subs)
+	for i := range latencies {
+		ls := make([]time.Duration, 0, total)
+
+		// values are nanoseconds since start, so latencies use the
+		// monotonic clock.
+		if _, err := ps.SubFunc(func(v interface{}) {
+			ls = append(ls, time.Since(start)-time.Duration(v.(int64)))
+			if len(ls) == total {
+				latencies[i] = ls
+				wg.Done()
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var pwg sync.WaitGroup
+	for p := 0; p < pubs; p++ {
+		pwg.Add(1)
+		go func() {
+			defer pwg.Done()
+
+			for i := 0; i < n && ctx.Err() == nil; i++ {
+				ps.Pub(int64(time.Since(start)))
+			}
+		}()
+	}
+	pwg.Wait()
+
+	waitc := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(waitc)
+	}()
+	select {
+	case <-waitc:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	res := &benchResult{
+		values:  total,
+		elapsed: time.Since(start),
+	}
+	for _, ls := range latencies {
+		res.delivered += len(ls)
+		res.latencies = append(res.latencies, ls...)
+	}
+	sort.Slice(res.latencies, func(i, j int) bool { return res.latencies[i] < res.latencies[j] })
+	return res, nil
+}
+
+// percentile returns the latency below which p percent of the latencies
+// fall.
+func (r *benchResult) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+
+	i := int(p / 100 * float64(len(r.latencies)))
+	if i >= len(r.latencies) {
+		i = len(r.latencies) - 1
+	}
+	return r.latencies[i]
+}
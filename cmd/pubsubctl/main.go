@@ -0,0 +1,134 @@
+// Command pubsubctl publishes to, tails and inspects a PubSub served over
+// gRPC by the rpc package, or shared in memory by the shm package, and
+// benchmarks the ring buffer.
+//
+// Usage:
+//
+//	pubsubctl [-addr host:port | -shm name] publish [-topic t] [-json] < lines
+//	pubsubctl [-addr host:port | -shm name] tail [-topic t] [-name n] [-offset seq] [-filter re] [-n count]
+//	pubsubctl [-admin url | -shm name] stats [-topic t]
+//	pubsubctl bench [-pubs n] [-subs n] [-size n] [-n count]
+//
+// Values are encoded with codec.JSON. Published lines are strings unless
+// -json is set, tailed values are printed as JSON, one per line.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/benburkert/pubsub/codec"
+	"github.com/benburkert/pubsub/rpc"
+	"github.com/benburkert/pubsub/shm"
+)
+
+const usage = `usage: pubsubctl [flags] <command> [command flags]
+
+commands:
+  publish  publish lines read from stdin
+  tail     print the values of a topic
+  stats    print the stats of topics or of a shared memory ring
+  bench    measure the throughput and latency of a local PubSub
+
+flags:
+`
+
+// env is the connection to the PubSub a command runs against.
+type env struct {
+	addr  string // gRPC server address
+	shm   string // shared memory ring name or path
+	admin string // admin handler URL
+
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+type command func(ctx context.Context, e *env, args []string) error
+
+var commands = map[string]command{
+	"publish": publish,
+	"tail":    tail,
+	"stats":   stats,
+	"bench":   bench,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	e := &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	if err := run(ctx, e, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "pubsubctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("pubsubctl", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprint(e.stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&e.addr, "addr", "", "`host:port` of a gRPC PubSub server")
+	fs.StringVar(&e.shm, "shm", "", "`name` or path of a shared memory ring")
+	fs.StringVar(&e.admin, "admin", "", "`url` of an admin handler, for stats")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+	return cmd(ctx, e, fs.Args()[1:])
+}
+
+// client dials the gRPC server.
+func (e *env) client() (*rpc.Client, func(), error) {
+	cc, err := grpc.NewClient(e.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return rpc.NewClient(cc), func() { cc.Close() }, nil
+}
+
+// ring opens the shared memory ring. A name without a slash is in shm.Dir.
+func (e *env) ring() (*shm.Ring, error) {
+	path := e.shm
+	if !strings.Contains(path, "/") {
+		path = shm.Path(path)
+	}
+	return shm.Open(path)
+}
+
+// transport returns an error unless exactly one of -addr and -shm is set.
+func (e *env) transport() error {
+	if (e.addr == "") == (e.shm == "") {
+		return errors.New("one of -addr or -shm is required")
+	}
+	return nil
+}
+
+// newTyped returns the codec of shared memory values. JSON objects and
+// arrays read by publish -json are registered along with the basic types.
+func newTyped() *codec.Typed {
+	reg := codec.NewRegistry()
+	reg.Register(map[string]interface{}(nil))
+	reg.Register([]interface{}(nil))
+	return &codec.Typed{Codec: codec.JSON, Registry: reg}
+}
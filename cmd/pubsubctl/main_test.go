@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/admin"
+	"github.com/benburkert/pubsub/rpc"
+	"github.com/benburkert/pubsub/shm"
+)
+
+func runCmd(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	e := &env{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}
+	err := run(ctx, e, args)
+	if err != nil {
+		t.Logf("stderr: %s", stderr.String())
+	}
+	return stdout.String(), err
+}
+
+func TestRPC(t *testing.T) {
+	ps, err := pubsub.New(16, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	rpc.RegisterPubSubServer(srv, rpc.NewServer(rpc.Single(ps)))
+	go srv.Serve(lis)
+	defer srv.Stop()
+	addr := lis.Addr().String()
+
+	if _, err := runCmd(t, "alpha\nbeta\n{\"n\":1}\n", "-addr", addr, "publish"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "{\"n\":2}\n", "-addr", addr, "publish", "-json"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCmd(t, "", "-addr", addr, "tail", "-offset", "0", "-filter", `a"$|2`, "-n", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\"alpha\"\n\"beta\"\n{\"n\":2}\n"; out != want {
+		t.Errorf("want tail output %q, got %q", want, out)
+	}
+
+	h := admin.New()
+	h.Register("events", ps)
+	hs := httptest.NewServer(h)
+	defer hs.Close()
+
+	out, err = runCmd(t, "", "-admin", hs.URL, "stats", "-topic", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "events") || !strings.Contains(out, "EXPIRED") {
+		t.Errorf("want topic stats, got:\n%s", out)
+	}
+}
+
+func TestShm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	r, err := shm.Create(path, 16, 64, 2)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer r.Close()
+
+	tailc := make(chan string, 1)
+	go func() {
+		out, err := runCmd(t, "", "-shm", path, "tail", "-n", "2")
+		if err != nil {
+			t.Error(err)
+		}
+		tailc <- out
+	}()
+
+	// wait for the tail reader before publishing.
+	for len(r.Stats().Readers) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := runCmd(t, "[1,2]\n\"two\"\n", "-shm", path, "publish", "-json"); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "[1,2]\n\"two\"\n", <-tailc; got != want {
+		t.Errorf("want tail output %q, got %q", want, got)
+	}
+
+	out, err := runCmd(t, "", "-shm", path, "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "slots 16, slot size 64, write seq 2") {
+		t.Errorf("want ring stats, got:\n%s", out)
+	}
+}
+
+func TestBench(t *testing.T) {
+	out, err := runCmd(t, "", "bench", "-pubs", "2", "-subs", "2", "-size", "8", "-n", "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"published 2000 values", "latency p50"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("want output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	if _, err := runCmd(t, "", "nope"); err == nil {
+		t.Error("want error for unknown command")
+	}
+	if _, err := runCmd(t, "", "publish"); err == nil {
+		t.Error("want error without -addr or -shm")
+	}
+}
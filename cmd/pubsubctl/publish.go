@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/shm"
+)
+
+func publish(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	topic := fs.String("topic", "", "`topic` to publish to")
+	isJSON := fs.Bool("json", false, "decode each line as a JSON value instead of publishing it as a string")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := e.transport(); err != nil {
+		return err
+	}
+
+	values := make(chan interface{})
+	errc := make(chan error, 1)
+	go func() {
+		defer close(values)
+		errc <- scanValues(ctx, e, *isJSON, values)
+	}()
+
+	if e.shm != "" {
+		return publishRing(e, values, errc)
+	}
+	return publishRPC(e, *topic, values, errc)
+}
+
+// scanValues sends the values of the lines of stdin to values.
+func scanValues(ctx context.Context, e *env, isJSON bool, values chan<- interface{}) error {
+	sc := bufio.NewScanner(e.stdin)
+	for sc.Scan() {
+		var v interface{} = sc.Text()
+		if isJSON {
+			if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+				return fmt.Errorf("invalid JSON line %q: %w", sc.Text(), err)
+			}
+		}
+
+		select {
+		case values <- v:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return sc.Err()
+}
+
+// publishRPC publishes values to topic through a local PubSub, with the
+// rpc Writer as its only subscriber.
+func publishRPC(e *env, topic string, values <-chan interface{}, errc <-chan error) error {
+	c, closeConn, err := e.client()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	ps, err := pubsub.New(64, 1)
+	if err != nil {
+		return err
+	}
+	defer ps.Close()
+
+	w := c.Writer(topic)
+	if _, err := ps.AddSubscriber(w); err != nil {
+		return err
+	}
+
+	for v := range values {
+		if err := ps.Pub(v); err != nil {
+			return err
+		}
+	}
+	ps.Close()
+
+	if err := <-errc; err != nil {
+		return err
+	}
+	if err := w.Err(); err != nil {
+		return err
+	}
+
+	published, rejected := w.Published()
+	fmt.Fprintf(e.stderr, "published %d, rejected %d\n", published, rejected)
+	return nil
+}
+
+func publishRing(e *env, values <-chan interface{}, errc <-chan error) error {
+	r, err := e.ring()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	enc := shm.NewEncoder(r, newTyped())
+
+	n := 0
+	for v := range values {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		n++
+	}
+	if err := <-errc; err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.stderr, "published %d\n", n)
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/benburkert/pubsub/admin"
+)
+
+func stats(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	topic := fs.String("topic", "", "only print `topic`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case e.admin != "":
+		return statsAdmin(ctx, e, *topic)
+	case e.shm != "":
+		return statsRing(e)
+	default:
+		return errors.New("one of -admin or -shm is required")
+	}
+}
+
+// statsAdmin prints the topics served by an admin.Handler.
+func statsAdmin(ctx context.Context, e *env, topic string) error {
+	u := strings.TrimSuffix(e.admin, "/") + "/"
+	if topic != "" {
+		u += "topics/" + url.PathEscape(topic)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, resp.Status)
+	}
+
+	var topics []admin.Topic
+	if topic != "" {
+		var t admin.Topic
+		err = json.NewDecoder(resp.Body).Decode(&t)
+		topics = append(topics, t)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&topics)
+	}
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tSUBS\tEXPIRED\tDUPLICATES\tPAUSED")
+	for _, t := range topics {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%t\n", t.Name, len(t.Subscriptions), t.Stats.Expired, t.Stats.Duplicates, t.Paused)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "TOPIC\tSUB\tNAME\tLAG\tDELIVERED\tDROPPED\tPAUSED")
+	for _, t := range topics {
+		for _, s := range t.Subscriptions {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%d\t%d\t%t\n", t.Name, s.ID, s.Name, s.Lag, s.Delivered, s.Dropped, s.Paused)
+		}
+	}
+	return tw.Flush()
+}
+
+// statsRing prints the positions of the writer and readers of a shared
+// memory ring.
+func statsRing(e *env) error {
+	r, err := e.ring()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	s := r.Stats()
+	fmt.Fprintf(e.stdout, "slots %d, slot size %d, write seq %d\n", s.Slots, s.SlotSize, s.WriteSeq)
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "READER\tSEQ\tLAG")
+	for i, seq := range s.Readers {
+		fmt.Fprintf(tw, "%d\t%d\t%d\n", i, seq, s.WriteSeq-seq)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+	"github.com/benburkert/pubsub/rpc"
+)
+
+func tail(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	topic := fs.String("topic", "", "`topic` to tail")
+	name := fs.String("name", "", "`name` of a durable subscription to resume")
+	offset := fs.Int64("offset", -1, "sequence number of the first value, -1 for the next value published")
+	filter := fs.String("filter", "", "only print values whose JSON matches the `regexp`")
+	count := fs.Int("n", 0, "stop after printing `count` values, 0 to never stop")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := e.transport(); err != nil {
+		return err
+	}
+
+	var re *regexp.Regexp
+	if *filter != "" {
+		var err error
+		if re, err = regexp.Compile(*filter); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	printed := 0
+	emit := func(data []byte) {
+		// values read before the tail stops are not printed.
+		if *count > 0 && printed >= *count {
+			return
+		}
+		if re != nil && !re.Match(data) {
+			return
+		}
+		fmt.Fprintf(e.stdout, "%s\n", data)
+		if printed++; printed == *count {
+			cancel()
+		}
+	}
+
+	if e.shm != "" {
+		if *offset >= 0 || *name != "" {
+			return errors.New("-offset and -name require -addr")
+		}
+		return tailRing(ctx, e, emit)
+	}
+
+	req := &rpc.SubscribeRequest{Topic: *topic, Name: *name}
+	if *offset >= 0 {
+		req.Offset = offset
+	}
+	return tailRPC(ctx, cancel, e, req, emit)
+}
+
+// tailRPC publishes the values of the remote subscription to a local
+// PubSub and prints them from a subscription to it.
+func tailRPC(ctx context.Context, cancel func(), e *env, req *rpc.SubscribeRequest, emit func([]byte)) error {
+	c, closeConn, err := e.client()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	ps, err := pubsub.New(64, 1)
+	if err != nil {
+		return err
+	}
+	defer ps.Close()
+
+	// the first encoding error stops the tail.
+	errc := make(chan error, 1)
+	if _, err := ps.SubFunc(func(v interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			select {
+			case errc <- err:
+				cancel()
+			default:
+			}
+			return
+		}
+		emit(data)
+	}); err != nil {
+		return err
+	}
+
+	r := c.Reader(req)
+	if err := ps.AddPublisher(r); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-r.Done():
+	}
+	ps.Close()
+
+	select {
+	case err := <-errc:
+		return err
+	default:
+	}
+	select {
+	case <-r.Done():
+		return r.Err()
+	default:
+		return nil
+	}
+}
+
+// tailRing prints the values of a shared memory ring. The values are
+// encoded by codec.Typed with codec.JSON, so their data is printed as is.
+func tailRing(ctx context.Context, e *env, emit func([]byte)) error {
+	r, err := e.ring()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	rd, err := r.NewReader()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	go func() {
+		<-ctx.Done()
+		rd.Close()
+	}()
+
+	for {
+		b, err := rd.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, data, err := codec.Split(b)
+		if err != nil {
+			return err
+		}
+		emit(data)
+	}
+}
@@ -0,0 +1,153 @@
+// Package codec serializes PubSub values for use outside of the process.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec marshals values to bytes and unmarshals bytes into values.
+type Codec interface {
+	// Name identifies the wire format, e.g. "json".
+	Name() string
+	// Marshal returns the encoding of v.
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into the value pointed to by v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSON encodes values with encoding/json.
+	JSON Codec = jsonCodec{}
+	// Gob encodes values with encoding/gob.
+	Gob Codec = gobCodec{}
+	// Proto encodes values that implement proto.Message.
+	Proto Codec = protoCodec{}
+	// Msgpack encodes values with MessagePack.
+	Msgpack Codec = msgpackCodec{}
+	// Raw passes byte slices and strings through unchanged.
+	Raw Codec = rawCodec{}
+)
+
+var (
+	codecmu sync.RWMutex
+	codecs  = map[string]Codec{}
+)
+
+func init() {
+	for _, c := range []Codec{JSON, Gob, Proto, Msgpack, Raw} {
+		Register(c)
+	}
+}
+
+// Register makes c available to Lookup by its name.
+func Register(c Codec) {
+	codecmu.Lock()
+	defer codecmu.Unlock()
+
+	codecs[c.Name()] = c
+}
+
+// Lookup returns the registered Codec with name.
+func Lookup(name string) (Codec, bool) {
+	codecmu.RLock()
+	defer codecmu.RUnlock()
+
+	c, ok := codecs[name]
+	return c, ok
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string { return "proto" }
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("codec: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("codec: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "raw" }
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("codec: can not raw encode %T", v)
+	}
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *[]byte:
+		*v = append((*v)[:0], data...)
+	case *string:
+		*v = string(data)
+	case *interface{}:
+		*v = append([]byte(nil), data...)
+	default:
+		return fmt.Errorf("codec: can not raw decode into %T", v)
+	}
+	return nil
+}
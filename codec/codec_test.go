@@ -0,0 +1,140 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestCodecs(t *testing.T) {
+	tests := []struct {
+		codec Codec
+		v     interface{}
+		ptr   interface{}
+	}{
+		{JSON, point{1, 2}, new(point)},
+		{Gob, point{1, 2}, new(point)},
+		{Msgpack, point{1, 2}, new(point)},
+		{Proto, wrapperspb.String("hello"), new(wrapperspb.StringValue)},
+		{Raw, []byte("hello"), new([]byte)},
+		{Raw, "hello", new(string)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.codec.Name(), func(t *testing.T) {
+			if c, ok := Lookup(test.codec.Name()); !ok || c != test.codec {
+				t.Errorf("want Lookup(%q)=%v, got %v", test.codec.Name(), test.codec, c)
+			}
+
+			data, err := test.codec.Marshal(test.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := test.codec.Unmarshal(data, test.ptr); err != nil {
+				t.Fatal(err)
+			}
+
+			if m, ok := test.ptr.(proto.Message); ok {
+				if !proto.Equal(test.v.(proto.Message), m) {
+					t.Errorf("want %v, got %v", test.v, m)
+				}
+			} else if got := reflect.ValueOf(test.ptr).Elem().Interface(); !reflect.DeepEqual(test.v, got) {
+				t.Errorf("want %v, got %v", test.v, got)
+			}
+		})
+	}
+}
+
+func TestTyped(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(point{})
+	reg.Register(&wrapperspb.StringValue{})
+	reg.RegisterName("example.Point", &point{})
+
+	tests := []struct {
+		codec Codec
+		v     interface{}
+	}{
+		{JSON, point{1, 2}},
+		{JSON, &point{3, 4}},
+		{JSON, "hello"},
+		{Gob, point{1, 2}},
+		{Gob, int64(7)},
+		{Msgpack, point{1, 2}},
+		{Msgpack, 1.5},
+		{Raw, []byte("hello")},
+		{Raw, "hello"},
+	}
+
+	for _, test := range tests {
+		typed := &Typed{Codec: test.codec, Registry: reg}
+
+		data, err := typed.Encode(test.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := typed.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(test.v, got) {
+			t.Errorf("%s: want %#v, got %#v", test.codec.Name(), test.v, got)
+		}
+	}
+
+	typed := &Typed{Codec: Proto, Registry: reg}
+	data, err := typed.Encode(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := typed.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sv, ok := got.(*wrapperspb.StringValue); !ok || sv.GetValue() != "hello" {
+		t.Errorf("want proto value %q, got %v", "hello", got)
+	}
+
+	if _, err := typed.Encode(struct{}{}); err == nil {
+		t.Error("want error for unregistered type")
+	}
+	if _, err := typed.Decode(data[:1]); err == nil {
+		t.Error("want error for short data")
+	}
+
+	data, err = (&Typed{Codec: JSON, Registry: reg}).Encode(point{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, payload, err := Split(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := reg.Name(point{}); name != want || string(payload) != `{"X":1,"Y":2}` {
+		t.Errorf("want split %q %s, got %q %s", want, `{"X":1,"Y":2}`, name, payload)
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{"", "string"},
+		{[]byte(nil), "[]uint8"},
+		{point{}, "github.com/benburkert/pubsub/codec.point"},
+		{&point{}, "*github.com/benburkert/pubsub/codec.point"},
+	}
+
+	for _, test := range tests {
+		if got := TypeName(reflect.TypeOf(test.v)); got != test.want {
+			t.Errorf("want TypeName(%T)=%q, got %q", test.v, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Registry maps type names to Go types, so that a value decoded in another
+// process gets back its concrete type.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}
+
+// DefaultRegistry is the Registry used by the package level Register
+// functions.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns a Registry with the basic Go types registered.
+func NewRegistry() *Registry {
+	r := &Registry{
+		types: make(map[string]reflect.Type),
+		names: make(map[reflect.Type]string),
+	}
+
+	for _, v := range []interface{}{
+		false, "", []byte(nil),
+		int(0), int8(0), int16(0), int32(0), int64(0),
+		uint(0), uint8(0), uint16(0), uint32(0), uint64(0),
+		float32(0), float64(0),
+	} {
+		r.Register(v)
+	}
+	return r
+}
+
+// RegisterType records the type of v in the DefaultRegistry.
+func RegisterType(v interface{}) {
+	DefaultRegistry.Register(v)
+}
+
+// Register records the type of v under its TypeName.
+func (r *Registry) Register(v interface{}) {
+	t := reflect.TypeOf(v)
+	r.RegisterName(TypeName(t), v)
+}
+
+// RegisterName records the type of v under name, for example to match a
+// type name used by another language.
+func (r *Registry) RegisterName(name string, v interface{}) {
+	t := reflect.TypeOf(v)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.types[name] = t
+	r.names[t] = name
+}
+
+// Name returns the registered name of the type of v.
+func (r *Registry) Name(v interface{}) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.names[reflect.TypeOf(v)]
+	return name, ok
+}
+
+// Type returns the type registered under name.
+func (r *Registry) Type(name string) (reflect.Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// TypeName returns the default registry name of t: the import path and name
+// of named types, and the type string of others.
+func TypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		return "*" + TypeName(t.Elem())
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+	return t.String()
+}
+
+var errShortData = errors.New("codec: short typed data")
+
+// Typed pairs a Codec with a Registry to encode interface{} values along
+// with their type name.
+type Typed struct {
+	Codec    Codec
+	Registry *Registry
+}
+
+// NewTyped returns a Typed for c that uses the DefaultRegistry.
+func NewTyped(c Codec) *Typed {
+	return &Typed{
+		Codec:    c,
+		Registry: DefaultRegistry,
+	}
+}
+
+// Encode returns the type name of v followed by the encoding of v. The type
+// of v must be registered.
+func (t *Typed) Encode(v interface{}) ([]byte, error) {
+	name, ok := t.Registry.Name(v)
+	if !ok {
+		return nil, fmt.Errorf("codec: type %T is not registered", v)
+	}
+
+	data, err := t.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, binary.MaxVarintLen64+len(name)+len(data))
+	buf = binary.AppendUvarint(buf, uint64(len(name)))
+	buf = append(buf, name...)
+	return append(buf, data...), nil
+}
+
+// Decode returns the value encoded by Encode as its registered type.
+func (t *Typed) Decode(data []byte) (interface{}, error) {
+	name, data, err := Split(data)
+	if err != nil {
+		return nil, err
+	}
+	return t.DecodeName(name, data)
+}
+
+// Split returns the type name and the Codec encoding of data encoded by
+// Typed.Encode, for readers that handle values of unregistered types.
+func Split(data []byte) (string, []byte, error) {
+	n, sz := binary.Uvarint(data)
+	if sz <= 0 || uint64(len(data)-sz) < n {
+		return "", nil, errShortData
+	}
+	return string(data[sz : sz+int(n)]), data[sz+int(n):], nil
+}
+
+// DecodeName decodes data encoded by the Codec as the type registered with
+// name, for formats that carry the type name apart from the data.
+func (t *Typed) DecodeName(name string, data []byte) (interface{}, error) {
+	typ, ok := t.Registry.Type(name)
+	if !ok {
+		return nil, fmt.Errorf("codec: type %q is not registered", name)
+	}
+
+	// pointer types, such as proto messages, decode into a new value of the
+	// element type.
+	if typ.Kind() == reflect.Ptr {
+		pv := reflect.New(typ.Elem())
+		if err := t.Codec.Unmarshal(data, pv.Interface()); err != nil {
+			return nil, err
+		}
+		return pv.Interface(), nil
+	}
+
+	pv := reflect.New(typ)
+	if err := t.Codec.Unmarshal(data, pv.Interface()); err != nil {
+		return nil, err
+	}
+	return pv.Elem().Interface(), nil
+}
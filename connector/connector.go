@@ -0,0 +1,275 @@
+// Package connector mirrors PubSub values to and from external brokers.
+//
+// A Publisher consumes messages from a Source, such as a Redis stream, a
+// NATS JetStream consumer or a Kafka consumer group, and publishes their
+// values. A message is acknowledged to its broker only once its value is
+// written to the PubSub, so a message is published at least once. A
+// Subscriber produces every value to a Sink, and can record the position
+// of the last value produced to resume from it.
+package connector
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+)
+
+// Message is a message consumed from a broker.
+type Message struct {
+	// ID is the broker position of the message, for error messages.
+	ID string
+	// Data is the encoded value.
+	Data []byte
+	// Ack acknowledges the message to the broker.
+	Ack func(context.Context) error
+}
+
+// Source consumes messages from a broker.
+type Source interface {
+	// Fetch waits for the next messages. It may return no messages if
+	// none arrived in time.
+	Fetch(ctx context.Context) ([]Message, error)
+}
+
+// Sink produces messages to a broker.
+type Sink interface {
+	Write(ctx context.Context, data []byte) error
+}
+
+// Codec encodes values as message data. A *codec.Typed is a Codec that
+// preserves the registered type of values.
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+// Untyped returns a Codec that encodes values with c and decodes message
+// data into generic values, such as maps for codec.JSON. Use it for
+// messages produced by programs that do not write type names.
+func Untyped(c codec.Codec) Codec {
+	return untyped{c}
+}
+
+type untyped struct {
+	c codec.Codec
+}
+
+func (u untyped) Encode(v interface{}) ([]byte, error) {
+	return u.c.Marshal(v)
+}
+
+func (u untyped) Decode(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := u.c.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Option configures a Publisher or a Subscriber.
+type Option func(*options)
+
+type options struct {
+	codec   Codec
+	offsets pubsub.OffsetStore
+	name    string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		codec: Untyped(codec.JSON),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCodec sets the codec of message data. It defaults to
+// Untyped(codec.JSON).
+func WithCodec(c Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// WithOffsetStore makes a Subscriber record the position after each value
+// produced under name, and resume from the stored position. Publishers
+// track their position with the broker instead.
+func WithOffsetStore(s pubsub.OffsetStore, name string) Option {
+	return func(o *options) {
+		o.offsets = s
+		o.name = name
+	}
+}
+
+// Publisher is a pubsub.Publisher that publishes the values of the messages
+// consumed from a Source.
+type Publisher struct {
+	src  Source
+	opts *options
+
+	donec chan struct{}
+	err   error
+}
+
+// NewPublisher returns a Publisher that consumes from src.
+func NewPublisher(src Source, opts ...Option) *Publisher {
+	return &Publisher{
+		src:   src,
+		opts:  newOptions(opts),
+		donec: make(chan struct{}),
+	}
+}
+
+// PublishTo starts publishing to ctx. Each message is acknowledged after
+// its value is written to the buffer. Messages whose value is rejected by a
+// publish rate limit are not acknowledged: a RedisSource consumes them again
+// from its pending entries and JetStream redelivers them, but a Kafka
+// consumer group commits past them once a later message is acknowledged.
+// Publishing stops on the first fetch, decode or ack error, or when the
+// PubSub is closed.
+func (p *Publisher) PublishTo(ctx *pubsub.Context) error {
+	fctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done:
+			cancel()
+		case <-p.donec:
+		}
+	}()
+
+	go func() {
+		defer close(p.donec)
+		defer ctx.Close()
+		defer cancel()
+
+		for {
+			msgs, err := p.src.Fetch(fctx)
+			if err != nil {
+				if fctx.Err() == nil {
+					p.err = err
+				}
+				return
+			}
+
+			for _, m := range msgs {
+				v, err := p.opts.codec.Decode(m.Data)
+				if err != nil {
+					p.err = fmt.Errorf("connector: decoding message %s: %w", m.ID, err)
+					return
+				}
+
+				// a value rejected by a publish rate limit is
+				// dropped, and its message left for the broker to
+				// redeliver.
+				if ctx.Buffer.WriteLimited(v) != nil {
+					continue
+				}
+
+				// the ack is not canceled with the fetch, a value
+				// written to the buffer is always acknowledged.
+				if err := m.Ack(context.Background()); err != nil {
+					p.err = err
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Done is closed once the Publisher stops publishing.
+func (p *Publisher) Done() <-chan struct{} {
+	return p.donec
+}
+
+// Err waits for the Publisher to stop and returns the error that stopped
+// it, or nil if the PubSub was closed.
+func (p *Publisher) Err() error {
+	<-p.donec
+	return p.err
+}
+
+// Subscriber is a pubsub.Subscriber that produces every value to a Sink.
+type Subscriber struct {
+	sink Sink
+	opts *options
+
+	donec chan struct{}
+	err   error
+}
+
+// NewSubscriber returns a Subscriber that produces to sink.
+func NewSubscriber(sink Sink, opts ...Option) *Subscriber {
+	return &Subscriber{
+		sink:  sink,
+		opts:  newOptions(opts),
+		donec: make(chan struct{}),
+	}
+}
+
+// SubscribeTo starts producing values read from ctx, from the stored
+// position if the Subscriber has an OffsetStore. A value's position is
+// stored once the sink accepts it. It stops on the first encode, write or
+// store error, when it is unsubscribed or when the PubSub is closed.
+func (s *Subscriber) SubscribeTo(ctx *pubsub.Context) error {
+	seq := int64(math.MaxInt64) // clamped to the write sequence
+	if s.opts.offsets != nil {
+		stored, ok, err := s.opts.offsets.Load(s.opts.name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			seq = stored
+		}
+	}
+
+	rfn := func(seq int64, v interface{}) bool {
+		if _, ok := v.(pubsub.MarkerChan); ok {
+			if v != ctx.Done && v != ctx.Unsub {
+				return true
+			}
+		} else if s.err = s.produce(seq, v); s.err == nil {
+			return true
+		}
+
+		close(s.donec)
+		ctx.Close()
+		return false
+	}
+
+	ctx.Track(ctx.Buffer.ReadFromSeq(seq, rfn))
+	return nil
+}
+
+func (s *Subscriber) produce(seq int64, v interface{}) error {
+	data, err := s.opts.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+	if err := s.sink.Write(context.Background(), data); err != nil {
+		return err
+	}
+
+	if s.opts.offsets == nil {
+		return nil
+	}
+	return s.opts.offsets.Store(s.opts.name, seq+1)
+}
+
+// Done is closed once the Subscriber stops producing.
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.donec
+}
+
+// Err waits for the Subscriber to stop and returns the error that stopped
+// it, or nil if the PubSub was closed.
+func (s *Subscriber) Err() error {
+	<-s.donec
+	return s.err
+}
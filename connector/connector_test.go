@@ -0,0 +1,214 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+)
+
+var errSink = errors.New("sink failed")
+
+// fakeSource returns batches sent on its channel.
+type fakeSource struct {
+	batches chan []Message
+
+	mu    sync.Mutex
+	acked []string
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{batches: make(chan []Message, 4)}
+}
+
+func (s *fakeSource) send(data ...string) {
+	var msgs []Message
+	for _, d := range data {
+		id := d
+		msgs = append(msgs, Message{
+			ID:   id,
+			Data: []byte(d),
+			Ack: func(context.Context) error {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+
+				s.acked = append(s.acked, id)
+				return nil
+			},
+		})
+	}
+	s.batches <- msgs
+}
+
+func (s *fakeSource) Fetch(ctx context.Context) ([]Message, error) {
+	select {
+	case msgs := <-s.batches:
+		return msgs, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *fakeSource) acks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.acked...)
+}
+
+// fakeSink records written data, and rejects failOn.
+type fakeSink struct {
+	mu     sync.Mutex
+	data   []string
+	failOn string
+}
+
+func (s *fakeSink) Write(_ context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if string(data) == s.failOn {
+		return errSink
+	}
+	s.data = append(s.data, string(data))
+	return nil
+}
+
+func (s *fakeSink) written() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.data...)
+}
+
+func TestPublisher(t *testing.T) {
+	ps, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotc := make(chan interface{}, 4)
+	if _, err := ps.SubFunc(func(v interface{}) { gotc <- v }); err != nil {
+		t.Fatal(err)
+	}
+
+	src := newFakeSource()
+	p := NewPublisher(src, WithCodec(Untyped(codec.Raw)))
+	if err := ps.AddPublisher(p); err != nil {
+		t.Fatal(err)
+	}
+
+	src.send("A", "B")
+	src.send("C")
+	for _, want := range []string{"A", "B", "C"} {
+		if got := <-gotc; !reflect.DeepEqual([]byte(want), got) {
+			t.Errorf("want value %q, got %q", want, got)
+		}
+	}
+
+	ps.Close()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := []string{"A", "B", "C"}, src.acks(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want acks %q, got %q", want, got)
+	}
+}
+
+func TestPublisherRateLimited(t *testing.T) {
+	ps, err := pubsub.New(8, 1, pubsub.WithPubRateLimit(pubsub.RateLimit{Rate: 1e-9, Burst: 2, Reject: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := newFakeSource()
+	p := NewPublisher(src, WithCodec(Untyped(codec.Raw)))
+	if err := ps.AddPublisher(p); err != nil {
+		t.Fatal(err)
+	}
+
+	src.send("A", "B", "C")
+	for ps.Stats().PubLimiter.Rejected == 0 {
+		runtime.Gosched()
+	}
+
+	ps.Close()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := []string{"A", "B"}, src.acks(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want acks %q, got %q", want, got)
+	}
+}
+
+func TestPublisherDecodeError(t *testing.T) {
+	ps, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	src := newFakeSource()
+	p := NewPublisher(src)
+	if err := ps.AddPublisher(p); err != nil {
+		t.Fatal(err)
+	}
+
+	src.send(`"ok"`, "not json")
+	if err := p.Err(); err == nil {
+		t.Error("want decode error")
+	}
+	if want, got := []string{`"ok"`}, src.acks(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want acks %q, got %q", want, got)
+	}
+}
+
+func TestSubscriberOffsets(t *testing.T) {
+	ps, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	store := pubsub.NewMemoryOffsetStore()
+	sink := &fakeSink{failOn: `"D"`}
+
+	s := NewSubscriber(sink, WithOffsetStore(store, "mirror"))
+	sub, err := ps.AddSubscriber(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps.Pub("A")
+	ps.Pub("B")
+	sub.Unsubscribe()
+	<-sub.Done()
+
+	// published while no Subscriber is running.
+	ps.Pub("C")
+
+	s = NewSubscriber(sink, WithOffsetStore(store, "mirror"))
+	sub, err = ps.AddSubscriber(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps.Pub("D")
+
+	if want, got := errSink, s.Err(); want != got {
+		t.Errorf("want error %q, got %q", want, got)
+	}
+	if want, got := []string{`"A"`, `"B"`, `"C"`}, sink.written(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want written %q, got %q", want, got)
+	}
+
+	// the stored position is the value the sink rejected, after A, B,
+	// the unsubscribe marker and C.
+	if seq, _, _ := store.Load("mirror"); seq != 4 {
+		t.Errorf("want stored position %d, got %d", 4, seq)
+	}
+}
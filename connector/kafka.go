@@ -0,0 +1,66 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// KafkaSource consumes Kafka topics as a member of a consumer group. The
+// client must be configured with kgo.ConsumeTopics, kgo.ConsumerGroup and
+// kgo.AutoCommitMarks, so that only acknowledged records are committed,
+// periodically and when the client leaves the group on Close.
+type KafkaSource struct {
+	c *kgo.Client
+}
+
+// NewKafkaSource returns a KafkaSource that polls c.
+func NewKafkaSource(c *kgo.Client) *KafkaSource {
+	return &KafkaSource{c: c}
+}
+
+// Fetch polls the next records.
+func (s *KafkaSource) Fetch(ctx context.Context) ([]Message, error) {
+	fetches := s.c.PollFetches(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, fe := range fetches.Errors() {
+		if errors.Is(fe.Err, kgo.ErrClientClosed) {
+			return nil, fe.Err
+		}
+		return nil, fmt.Errorf("connector: fetching %s/%d: %w", fe.Topic, fe.Partition, fe.Err)
+	}
+
+	var msgs []Message
+	fetches.EachRecord(func(r *kgo.Record) {
+		msgs = append(msgs, Message{
+			ID:   fmt.Sprintf("%s/%d/%d", r.Topic, r.Partition, r.Offset),
+			Data: r.Value,
+			Ack: func(context.Context) error {
+				s.c.MarkCommitRecords(r)
+				return nil
+			},
+		})
+	})
+	return msgs, nil
+}
+
+// KafkaSink produces records to a Kafka topic and waits for them to be
+// acknowledged.
+type KafkaSink struct {
+	c     *kgo.Client
+	topic string
+}
+
+// NewKafkaSink returns a KafkaSink that produces to topic with c.
+func NewKafkaSink(c *kgo.Client, topic string) *KafkaSink {
+	return &KafkaSink{c: c, topic: topic}
+}
+
+// Write produces a record with the value data.
+func (s *KafkaSink) Write(ctx context.Context, data []byte) error {
+	return s.c.ProduceSync(ctx, &kgo.Record{Topic: s.topic, Value: data}).FirstErr()
+}
@@ -0,0 +1,76 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/benburkert/pubsub"
+)
+
+func TestKafka(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	producer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumerGroup("mirror"),
+		kgo.ConsumeTopics("events"),
+		kgo.AutoCommitMarks(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	src, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSubscriber(NewKafkaSink(producer, "events"))
+	if _, err := src.AddSubscriber(s); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotc := make(chan interface{}, 4)
+	if _, err := dst.SubFunc(func(v interface{}) { gotc <- v }); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPublisher(NewKafkaSource(consumer))
+	if err := dst.AddPublisher(p); err != nil {
+		t.Fatal(err)
+	}
+
+	src.Pub("A")
+	src.Pub("B")
+	for _, want := range []interface{}{"A", "B"} {
+		if got := <-gotc; want != got {
+			t.Errorf("want value %v, got %v", want, got)
+		}
+	}
+
+	src.Close()
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	dst.Close()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,90 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSSource consumes the messages of a NATS JetStream stream with a
+// durable consumer. Messages that are not acknowledged in time are
+// redelivered by the server.
+type NATSSource struct {
+	cons jetstream.Consumer
+
+	// Batch is the most messages read per fetch. It defaults to 100.
+	Batch int
+	// Wait is how long a fetch waits for messages. It defaults to one
+	// second.
+	Wait time.Duration
+}
+
+// NewNATSSource returns a NATSSource for the durable consumer of stream,
+// filtered to subject. The consumer is created if it does not exist.
+func NewNATSSource(ctx context.Context, js jetstream.JetStream, stream, durable, subject string) (*NATSSource, error) {
+	cons, err := js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       durable,
+		FilterSubject: subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATSSource{
+		cons:  cons,
+		Batch: 100,
+		Wait:  time.Second,
+	}, nil
+}
+
+// Fetch reads the next messages of the consumer.
+func (s *NATSSource) Fetch(ctx context.Context) ([]Message, error) {
+	batch, err := s.cons.Fetch(s.Batch, jetstream.FetchMaxWait(s.Wait))
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []Message
+	for m := range batch.Messages() {
+		msgs = append(msgs, natsMessage(m))
+	}
+	if err := batch.Error(); err != nil && !errors.Is(err, jetstream.ErrNoMessages) {
+		return msgs, err
+	}
+	return msgs, ctx.Err()
+}
+
+func natsMessage(m jetstream.Msg) Message {
+	id := m.Subject()
+	if md, err := m.Metadata(); err == nil {
+		id = strconv.FormatUint(md.Sequence.Stream, 10)
+	}
+
+	return Message{
+		ID:   id,
+		Data: m.Data(),
+		Ack:  m.DoubleAck,
+	}
+}
+
+// NATSSink publishes messages to a subject of a NATS JetStream stream and
+// waits for the stream to store them.
+type NATSSink struct {
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewNATSSink returns a NATSSink that publishes to subject.
+func NewNATSSink(js jetstream.JetStream, subject string) *NATSSink {
+	return &NATSSink{js: js, subject: subject}
+}
+
+// Write publishes data to the subject.
+func (s *NATSSink) Write(ctx context.Context, data []byte) error {
+	_, err := s.js.Publish(ctx, s.subject, data)
+	return err
+}
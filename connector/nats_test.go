@@ -0,0 +1,114 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/benburkert/pubsub"
+)
+
+func runNATS(t *testing.T) jetstream.JetStream {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	t.Cleanup(srv.Shutdown)
+
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return js
+}
+
+func TestNATS(t *testing.T) {
+	js := runNATS(t)
+
+	ctx := context.Background()
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     "EVENTS",
+		Subjects: []string{"events.>"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSubscriber(NewNATSSink(js, "events.mirror"))
+	if _, err := src.AddSubscriber(s); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotc := make(chan interface{}, 4)
+	if _, err := dst.SubFunc(func(v interface{}) { gotc <- v }); err != nil {
+		t.Fatal(err)
+	}
+
+	ns, err := NewNATSSource(ctx, js, "EVENTS", "mirror", "events.mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Wait = 50 * time.Millisecond
+
+	p := NewPublisher(ns)
+	if err := dst.AddPublisher(p); err != nil {
+		t.Fatal(err)
+	}
+
+	src.Pub("A")
+	src.Pub(2.5)
+	for _, want := range []interface{}{"A", 2.5} {
+		if got := <-gotc; want != got {
+			t.Errorf("want value %v, got %v", want, got)
+		}
+	}
+
+	src.Close()
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	dst.Close()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ns.cons.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NumAckPending != 0 || info.AckFloor.Stream != 2 {
+		t.Errorf("want both messages acknowledged, got %d pending, ack floor %d", info.NumAckPending, info.AckFloor.Stream)
+	}
+}
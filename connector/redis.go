@@ -0,0 +1,151 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisField is the stream entry field that holds message data.
+const redisField = "data"
+
+// RedisSource consumes a Redis stream as a member of a consumer group.
+// Entries delivered to the consumer but never acknowledged, such as those
+// in flight when a previous Publisher stopped, are consumed again first.
+// Entries left unacknowledged while consuming, such as those rejected by a
+// publish rate limit, are consumed again once the stream is idle, or after
+// Retry if new entries keep arriving.
+type RedisSource struct {
+	c                       redis.UniversalClient
+	stream, group, consumer string
+
+	// Count is the most entries read per fetch. It defaults to 100.
+	Count int64
+	// Block is how long a fetch waits for new entries. It defaults to one
+	// second.
+	Block time.Duration
+	// Retry is the longest time between reads of the pending entries while
+	// new entries keep arriving. It defaults to 30 seconds.
+	Retry time.Duration
+
+	created bool
+
+	// pendingID is the ID after which the entries pending for the consumer
+	// are read next, empty while new entries are read.
+	pendingID string
+	scanned   time.Time // start of the last read of the pending entries
+}
+
+// NewRedisSource returns a RedisSource that reads stream as consumer of
+// group. The group and stream are created if they do not exist.
+func NewRedisSource(c redis.UniversalClient, stream, group, consumer string) *RedisSource {
+	return &RedisSource{
+		c:         c,
+		stream:    stream,
+		group:     group,
+		consumer:  consumer,
+		Count:     100,
+		Block:     time.Second,
+		Retry:     30 * time.Second,
+		pendingID: "0",
+	}
+}
+
+// Fetch reads the next entries of the stream.
+func (s *RedisSource) Fetch(ctx context.Context) ([]Message, error) {
+	if !s.created {
+		err := s.c.XGroupCreateMkStream(ctx, s.stream, s.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, err
+		}
+		s.created = true
+	}
+
+	if s.pendingID == "" && time.Since(s.scanned) >= s.Retry {
+		s.pendingID = "0"
+	}
+	if s.pendingID == "0" {
+		s.scanned = time.Now()
+	}
+
+	id, block := ">", s.Block
+	if s.pendingID != "" {
+		id, block = s.pendingID, -1
+	}
+
+	streams, err := s.c.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.group,
+		Consumer: s.consumer,
+		Streams:  []string{s.stream, id},
+		Count:    s.Count,
+		Block:    block,
+	}).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	var msgs []Message
+	for _, st := range streams {
+		for _, x := range st.Messages {
+			msgs = append(msgs, s.message(x))
+		}
+	}
+
+	switch {
+	case s.pendingID == "":
+		if len(msgs) == 0 {
+			s.pendingID = "0" // idle, read the pending entries again
+		}
+	case len(msgs) == 0:
+		s.pendingID = ""
+	default:
+		s.pendingID = msgs[len(msgs)-1].ID
+	}
+	return msgs, nil
+}
+
+func (s *RedisSource) message(x redis.XMessage) Message {
+	var data []byte
+	switch v := x.Values[redisField].(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	}
+
+	return Message{
+		ID:   x.ID,
+		Data: data,
+		Ack: func(ctx context.Context) error {
+			return s.c.XAck(ctx, s.stream, s.group, x.ID).Err()
+		},
+	}
+}
+
+// RedisSink appends messages to a Redis stream.
+type RedisSink struct {
+	c      redis.UniversalClient
+	stream string
+
+	// MaxLen caps the length of the stream, approximately, if it is not
+	// zero.
+	MaxLen int64
+}
+
+// NewRedisSink returns a RedisSink that appends to stream.
+func NewRedisSink(c redis.UniversalClient, stream string) *RedisSink {
+	return &RedisSink{c: c, stream: stream}
+}
+
+// Write appends data to the stream.
+func (s *RedisSink) Write(ctx context.Context, data []byte) error {
+	return s.c.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.MaxLen,
+		Approx: s.MaxLen > 0,
+		Values: map[string]interface{}{redisField: data},
+	}).Err()
+}
@@ -0,0 +1,172 @@
+package connector
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/pubsubtest"
+)
+
+func TestRedis(t *testing.T) {
+	c := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer c.Close()
+
+	src, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSubscriber(NewRedisSink(c, "events"))
+	if _, err := src.AddSubscriber(s); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotc := make(chan interface{}, 4)
+	if _, err := dst.SubFunc(func(v interface{}) { gotc <- v }); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := NewRedisSource(c, "events", "mirror", "one")
+	rs.Block = 10 * time.Millisecond
+	p := NewPublisher(rs)
+	if err := dst.AddPublisher(p); err != nil {
+		t.Fatal(err)
+	}
+
+	src.Pub("A")
+	src.Pub(map[string]interface{}{"B": true})
+	for _, want := range []interface{}{"A", map[string]interface{}{"B": true}} {
+		if got := <-gotc; !reflect.DeepEqual(want, got) {
+			t.Errorf("want value %v, got %v", want, got)
+		}
+	}
+
+	src.Close()
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	dst.Close()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := c.XPending(context.Background(), "events", "mirror").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("want no pending entries, got %d", pending.Count)
+	}
+}
+
+func TestRedisPending(t *testing.T) {
+	c := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer c.Close()
+
+	ctx := context.Background()
+	sink := NewRedisSink(c, "events")
+	for _, v := range []string{`"A"`, `"B"`} {
+		if err := sink.Write(ctx, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rs := NewRedisSource(c, "events", "mirror", "one")
+	msgs, err := rs.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 0, len(msgs); want != got {
+		t.Fatalf("want %d pending messages, got %d", want, got)
+	}
+	if msgs, err = rs.Fetch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(msgs); want != got {
+		t.Fatalf("want %d messages, got %d", want, got)
+	}
+	if err := msgs[0].Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted consumer gets the unacknowledged entry first.
+	rs = NewRedisSource(c, "events", "mirror", "one")
+	if msgs, err = rs.Fetch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || string(msgs[0].Data) != `"B"` {
+		t.Errorf("want pending message %q, got %v", `"B"`, msgs)
+	}
+}
+
+func TestRedisRedeliver(t *testing.T) {
+	c := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer c.Close()
+
+	ctx := context.Background()
+	sink := NewRedisSink(c, "events")
+	for _, v := range []string{`"A"`, `"B"`} {
+		if err := sink.Write(ctx, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock := pubsubtest.NewFakeClock(time.Unix(0, 0))
+	ps, err := pubsub.New(8, 1, pubsub.WithClock(clock), pubsub.WithPubRateLimit(pubsub.RateLimit{
+		Rate:   1,
+		Reject: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotc := make(chan interface{}, 2)
+	if _, err := ps.SubFunc(func(v interface{}) { gotc <- v }); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := NewRedisSource(c, "events", "mirror", "one")
+	rs.Block = 10 * time.Millisecond
+	p := NewPublisher(rs)
+	if err := ps.AddPublisher(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-gotc; got != "A" {
+		t.Fatalf("want value %q, got %v", "A", got)
+	}
+	for ps.Stats().PubLimiter.Rejected == 0 {
+		runtime.Gosched()
+	}
+
+	// B was rejected and left pending, it is consumed again once the
+	// stream is idle.
+	clock.Advance(time.Second)
+	if got := <-gotc; got != "B" {
+		t.Errorf("want value %q, got %v", "B", got)
+	}
+
+	ps.Close()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := c.XPending(ctx, "events", "mirror").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("want no pending entries, got %d", pending.Count)
+	}
+}
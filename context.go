@@ -12,4 +12,15 @@ type Context struct {
 	Buffer *Buffer
 	Done   MarkerChan
 	Close  func()
+
+	// Unsub is written to the buffer when a subscriber is unsubscribed.
+	// Subscribers stop when they read it, the same as Done. It is nil for
+	// publishers.
+	Unsub MarkerChan
+
+	// Track passes the Reader of a subscriber to its Subscription for
+	// Lag, Delivered, Pause and Resume. It must be called before
+	// SubscribeTo returns, so Done waits for the Reader to stop. It is nil
+	// for publishers.
+	Track func(*Reader)
 }
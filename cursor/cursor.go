@@ -0,0 +1,58 @@
+package cursor
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Cursor marks a sequence number in a ring buffer. The sequence grows
+// without bound, the ring buffer mask is only applied to find its position.
+// Cursors are padded to fill their own cache lines so that cursors updated
+// by different goroutines do not share one.
+type Cursor struct {
+	_ cpu.CacheLinePad
+
+	seq  atomic.Int64
+	mask int64
+
+	_ cpu.CacheLinePad
+}
+
+// New allocates a new Cursor at seq for a ring buffer mask.
+func New(seq int64, mask int) *Cursor {
+	c := &Cursor{}
+	c.Init(seq, mask)
+	return c
+}
+
+// Init sets up a Cursor in place, such as a Cursor in shared memory. It
+// must not be called while the Cursor is in use.
+func (c *Cursor) Init(seq int64, mask int) {
+	c.mask = int64(mask)
+	c.seq.Store(seq)
+}
+
+// Seq returns the current sequence number, or -1 if the cursor is reset.
+func (c *Cursor) Seq() int64 {
+	return c.seq.Load()
+}
+
+// Pos returns the current position index, or -1 if the cursor is reset.
+func (c *Cursor) Pos() int {
+	seq := c.seq.Load()
+	if seq < 0 {
+		return -1
+	}
+	return int(seq & c.mask)
+}
+
+// Inc moves the sequence forward one space and returns it.
+func (c *Cursor) Inc() int64 {
+	return c.seq.Add(1)
+}
+
+// Reset clears the sequence.
+func (c *Cursor) Reset() {
+	c.seq.Store(-1)
+}
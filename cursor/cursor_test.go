@@ -23,9 +23,26 @@ func TestCursor(t *testing.T) {
 	if p := c.Pos(); p != 0 {
 		t.Fatalf("cursor did not wrap: want pos(c)=0, got %d", p)
 	}
+	if s := c.Seq(); s != 8 {
+		t.Fatalf("want seq(c)=%d, got %d", 8, s)
+	}
 
 	c.Reset()
 	if p := c.Pos(); p != -1 {
 		t.Fatalf("want pos(c)=%d, got %d", -1, p)
 	}
+	if s := c.Seq(); s != -1 {
+		t.Fatalf("want seq(c)=%d, got %d", -1, s)
+	}
+}
+
+func TestCursorSeqWrap(t *testing.T) {
+	c := New(1<<40-1, 7)
+
+	if s := c.Inc(); s != 1<<40 {
+		t.Fatalf("want seq(c)=%d, got %d", int64(1<<40), s)
+	}
+	if p := c.Pos(); p != 0 {
+		t.Fatalf("want pos(c)=%d, got %d", 0, p)
+	}
 }
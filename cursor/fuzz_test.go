@@ -0,0 +1,94 @@
+package cursor
+
+import "testing"
+
+func FuzzCursor(f *testing.F) {
+	f.Add(int64(0), uint8(3), uint16(10))
+	f.Add(int64(7), uint8(3), uint16(1))
+	f.Add(int64(1<<62), uint8(10), uint16(1000))
+
+	f.Fuzz(func(t *testing.T, seq int64, bits uint8, n uint16) {
+		if seq < 0 {
+			seq = -(seq + 1)
+		}
+		if seq > 1<<62 {
+			seq >>= 1
+		}
+		mask := 1<<(bits%31) - 1
+
+		c := New(seq, mask)
+		for i := 0; i < int(n); i++ {
+			if s := c.Seq(); s != seq {
+				t.Fatalf("want seq(c)=%d, got %d", seq, s)
+			}
+			if p := c.Pos(); p != int(seq)&mask {
+				t.Fatalf("want pos(c)=%d, got %d", int(seq)&mask, p)
+			}
+			if p := c.Pos(); p < 0 || p > mask {
+				t.Fatalf("want pos(c) in [0, %d], got %d", mask, p)
+			}
+
+			seq++
+			if s := c.Inc(); s != seq {
+				t.Fatalf("want inc(c)=%d, got %d", seq, s)
+			}
+		}
+
+		c.Reset()
+		if s := c.Seq(); s != -1 {
+			t.Fatalf("want reset seq(c)=%d, got %d", -1, s)
+		}
+		if p := c.Pos(); p != -1 {
+			t.Fatalf("want reset pos(c)=%d, got %d", -1, p)
+		}
+	})
+}
+
+// FuzzSliceAlloc checks a Slice against a model of which cursors are in
+// use. Each op byte either allocates (low bit clear) or resets the cursor
+// at an index (low bit set).
+func FuzzSliceAlloc(f *testing.F) {
+	f.Add(uint8(4), []byte{0, 0, 0, 0, 0, 1, 0, 3, 5})
+	f.Add(uint8(1), []byte{0, 0, 1, 1, 0})
+
+	f.Fuzz(func(t *testing.T, size uint8, ops []byte) {
+		size = size%16 + 1
+		cs := MakeSlice(int(size), 7)
+		used := make(map[*Cursor]int64)
+
+		for i, op := range ops {
+			seq := int64(i)
+			if op&1 == 0 {
+				c, ok := cs.TryAlloc(seq)
+				if full := len(used) == len(cs); ok == full {
+					t.Fatalf("op %d: want alloc ok=%t with %d of %d in use, got %t", i, !full, len(used), len(cs), ok)
+				}
+				if !ok {
+					continue
+				}
+				if _, dup := used[c]; dup {
+					t.Fatalf("op %d: cursor allocated twice", i)
+				}
+				if s := c.Seq(); s != seq {
+					t.Fatalf("op %d: want alloc seq(c)=%d, got %d", i, seq, s)
+				}
+				used[c] = seq
+				continue
+			}
+
+			c := cs[int(op>>1)%len(cs)]
+			c.Reset()
+			delete(used, c)
+		}
+
+		for _, c := range cs {
+			want, ok := used[c]
+			if !ok {
+				want = -1
+			}
+			if s := c.Seq(); s != want {
+				t.Fatalf("want seq(c)=%d, got %d", want, s)
+			}
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package cursor
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+var ports = []struct{ goos, goarch string }{
+	{"darwin", "amd64"},
+	{"darwin", "arm64"},
+	{"linux", "386"},
+	{"linux", "amd64"},
+	{"linux", "arm"},
+	{"linux", "arm64"},
+	{"linux", "ppc64le"},
+	{"linux", "riscv64"},
+	{"linux", "s390x"},
+	{"windows", "386"},
+	{"windows", "amd64"},
+	{"js", "wasm"},
+}
+
+func TestCrossCompile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping cross compile in short mode")
+	}
+
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	for _, port := range ports {
+		port := port
+		t.Run(port.goos+"/"+port.goarch, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := exec.Command(gobin, "build", "github.com/benburkert/pubsub/...")
+			cmd.Env = append(os.Environ(), "GOOS="+port.goos, "GOARCH="+port.goarch, "CGO_ENABLED=0")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%s\n%s", err, out)
+			}
+		})
+	}
+}
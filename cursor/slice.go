@@ -11,3 +11,23 @@ func MakeSlice(size, mask int) Slice {
 	}
 	return s
 }
+
+// Alloc allocates the next unused or reset Cursor.
+func (s Slice) Alloc(seq int64) *Cursor {
+	for {
+		if c, ok := s.TryAlloc(seq); ok {
+			return c
+		}
+	}
+}
+
+// TryAlloc allocates an unused or reset Cursor, or returns false if every
+// Cursor is in use.
+func (s Slice) TryAlloc(seq int64) (*Cursor, bool) {
+	for i := range s {
+		if s[i].seq.CompareAndSwap(-1, seq) {
+			return s[i], true
+		}
+	}
+	return nil, false
+}
@@ -13,9 +13,29 @@ func TestCursorSlice(t *testing.T) {
 	}
 
 	for i := range cs {
-		c := cs.Alloc(i)
-		if p := c.Pos(); p != i {
-			t.Fatalf("want alloc pos(c)=%d, got %d", i, p)
+		c := cs.Alloc(int64(i))
+		if s := c.Seq(); s != int64(i) {
+			t.Fatalf("want alloc seq(c)=%d, got %d", i, s)
 		}
+		if p := c.Pos(); p != i&7 {
+			t.Fatalf("want alloc pos(c)=%d, got %d", i&7, p)
+		}
+	}
+}
+
+func TestCursorSliceTryAlloc(t *testing.T) {
+	cs := MakeSlice(2, 7)
+
+	a, _ := cs.TryAlloc(1)
+	if _, ok := cs.TryAlloc(2); !ok {
+		t.Fatal("want second cursor allocated")
+	}
+	if _, ok := cs.TryAlloc(3); ok {
+		t.Fatal("want allocation to fail when every cursor is in use")
+	}
+
+	a.Reset()
+	if c, ok := cs.TryAlloc(4); !ok || c != a {
+		t.Fatal("want reset cursor allocated again")
 	}
 }
@@ -0,0 +1,95 @@
+package pubsub
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benburkert/pubsub/cursor"
+)
+
+// DebugDump writes the subscriptions of the PubSub and the state of its
+// buffer to w, for postmortems of stuck publishers and subscribers.
+func (ps *PubSub) DebugDump(w io.Writer) {
+	subs := ps.Subscriptions()
+	fmt.Fprintf(w, "pubsub: closed=%t subs=%d/%d\n", ps.isClosed(), len(subs), ps.subMax)
+
+	fmt.Fprintln(w, "subscriptions:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  id\tname\tcursor\tseq\tlag\tdelivered\tpaused")
+	for _, s := range subs {
+		r := s.r.Load()
+		if r == nil {
+			fmt.Fprintf(tw, "  %d\t\t-\t-\t-\t-\t-\n", s.id)
+			continue
+		}
+		fmt.Fprintf(tw, "  %d\t%s\t%d\t%d\t%d\t%d\t%t\n", s.id, ps.readerName(r),
+			ps.buffer.cursorIndex(r.c), r.Seq(), r.Lag(), r.Delivered(), r.Paused())
+	}
+	tw.Flush()
+
+	ps.buffer.DebugDump(w)
+}
+
+// DebugDump writes the write and reader cursors and the slots of the buffer
+// to w. Cursors are always written, slots only while no writer holds the
+// buffer, so it does not block behind a stuck writer.
+func (b *Buffer) DebugDump(w io.Writer) {
+	wseq, size := b.wcursor.Seq(), len(b.data)
+	fmt.Fprintf(w, "buffer: size=%d first=%d write seq=%d pos=%d\n", size, b.first, wseq, b.wcursor.Pos())
+
+	fmt.Fprintln(w, "cursors:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  index\tseq\tpos\tlag")
+	for i, c := range b.rcursors {
+		if seq := c.Seq(); seq >= 0 {
+			fmt.Fprintf(tw, "  %d\t%d\t%d\t%d\n", i, seq, c.Pos(), wseq-seq)
+		}
+	}
+	tw.Flush()
+
+	if !b.mu.TryRLock() {
+		fmt.Fprintln(w, "slots: buffer locked by a writer")
+		return
+	}
+	defer b.mu.RUnlock()
+
+	fmt.Fprintln(w, "slots:")
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  pos\tseq\texpires\tvalue")
+	wseq, oseq := b.wcursor.Seq(), b.oldest()
+	for seq := oseq; seq < wseq; seq++ {
+		pos := int(seq & b.mask)
+
+		exp := "-"
+		if b.exps[pos] != 0 {
+			exp = time.Unix(0, b.exps[pos]).Format(time.RFC3339Nano)
+		}
+		fmt.Fprintf(tw, "  %d\t%d\t%s\t%s\n", pos, seq, exp, dumpValue(b.data[pos]))
+	}
+	tw.Flush()
+}
+
+// cursorIndex returns the index of reader cursor c, or -1.
+func (b *Buffer) cursorIndex(c *cursor.Cursor) int {
+	for i := range b.rcursors {
+		if b.rcursors[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// dumpValue formats v for a dump, truncated to one short line.
+func dumpValue(v interface{}) string {
+	if _, ok := v.(MarkerChan); ok {
+		return "<marker>"
+	}
+
+	s := fmt.Sprintf("%#v", v)
+	if len(s) > 64 {
+		s = s[:61] + "..."
+	}
+	return s
+}
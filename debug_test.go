@@ -0,0 +1,38 @@
+package pubsub
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDebugDump(t *testing.T) {
+	ps, err := New(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unblock := make(chan struct{})
+	if _, err := ps.SubFunc(func(interface{}) { <-unblock }, WithName("slow")); err != nil {
+		t.Fatal(err)
+	}
+	ps.PubSlice([]interface{}{"A", "B"})
+
+	var buf bytes.Buffer
+	ps.DebugDump(&buf)
+	close(unblock)
+	ps.Close()
+
+	out := buf.String()
+	for _, want := range []string{
+		"pubsub: closed=false subs=1/2",
+		"slow",
+		"buffer: size=4 first=0 write seq=2 pos=2",
+		`"A"`,
+		`"B"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("want dump to contain %q, got:\n%s", want, out)
+		}
+	}
+}
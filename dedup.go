@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Dedup configures the duplicate filter of a PubSub. A value is a duplicate
+// if its ID was already published within the window. At least one of Size
+// or Window should be set, otherwise every ID is remembered forever.
+type Dedup struct {
+	// ID returns the identity of v, or nil if v should never be treated as
+	// a duplicate. IDs must be comparable.
+	ID func(v interface{}) interface{}
+
+	// Size is the number of most recent IDs remembered.
+	Size int
+
+	// Window is how long an ID is remembered after it is first published.
+	Window time.Duration
+}
+
+type dedupEntry struct {
+	id interface{}
+	at time.Time
+}
+
+type dedupSet struct {
+	dropped uint64 // first for 64-bit alignment of atomic ops
+
+	mu    sync.Mutex
+	cfg   Dedup
+	clock Clock
+
+	ll  *list.List
+	ids map[interface{}]*list.Element
+}
+
+func newDedupSet(cfg Dedup) *dedupSet {
+	return &dedupSet{
+		cfg:   cfg,
+		clock: realClock{},
+		ll:    list.New(),
+		ids:   make(map[interface{}]*list.Element),
+	}
+}
+
+// seen records the ID of v and reports whether it was already in the window.
+func (d *dedupSet) seen(v interface{}) bool {
+	if _, ok := v.(MarkerChan); ok {
+		return false
+	}
+
+	id := d.cfg.ID(v)
+	if id == nil {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	d.evict(now)
+
+	if _, ok := d.ids[id]; ok {
+		atomic.AddUint64(&d.dropped, 1)
+		return true
+	}
+
+	d.ids[id] = d.ll.PushFront(&dedupEntry{id: id, at: now})
+	if d.cfg.Size > 0 && d.ll.Len() > d.cfg.Size {
+		d.remove(d.ll.Back())
+	}
+	return false
+}
+
+// forget removes the IDs of vs recorded by seen, so values that were not
+// published are not treated as duplicates when they are published again.
+func (d *dedupSet) forget(vs ...interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, v := range vs {
+		if _, ok := v.(MarkerChan); ok {
+			continue
+		}
+		id := d.cfg.ID(v)
+		if id == nil {
+			continue
+		}
+		if e, ok := d.ids[id]; ok {
+			d.remove(e)
+		}
+	}
+}
+
+func (d *dedupSet) filter(vs []interface{}) []interface{} {
+	s := make([]interface{}, 0, len(vs))
+	for _, v := range vs {
+		if !d.seen(v) {
+			s = append(s, v)
+		}
+	}
+	return s
+}
+
+func (d *dedupSet) dropCount() uint64 {
+	return atomic.LoadUint64(&d.dropped)
+}
+
+// assumes d.mu Lock held
+func (d *dedupSet) evict(now time.Time) {
+	if d.cfg.Window <= 0 {
+		return
+	}
+
+	for e := d.ll.Back(); e != nil; e = d.ll.Back() {
+		if now.Sub(e.Value.(*dedupEntry).at) < d.cfg.Window {
+			return
+		}
+		d.remove(e)
+	}
+}
+
+// assumes d.mu Lock held
+func (d *dedupSet) remove(e *list.Element) {
+	d.ll.Remove(e)
+	delete(d.ids, e.Value.(*dedupEntry).id)
+}
@@ -0,0 +1,146 @@
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedupSet(t *testing.T) {
+	clock := newFakeClock()
+	d := newDedupSet(Dedup{
+		ID:     func(v interface{}) interface{} { return v },
+		Size:   2,
+		Window: time.Minute,
+	})
+	d.clock = clock
+
+	steps := []struct {
+		v    interface{}
+		dup  bool
+		wait time.Duration
+	}{
+		{v: "A"},
+		{v: "A", dup: true},
+		{v: "B"},
+		{v: "C"}, // evicts A from the size bounded window
+		{v: "A"}, // evicts B
+		{v: "C", dup: true, wait: time.Minute},
+		{v: "C"}, // window elapsed
+	}
+
+	for i, step := range steps {
+		if dup := d.seen(step.v); dup != step.dup {
+			t.Errorf("step %d: want seen(%v)=%t, got %t", i, step.v, step.dup, dup)
+		}
+		clock.Advance(step.wait)
+	}
+
+	if n := d.dropCount(); n != 2 {
+		t.Errorf("want dropped=2, got %d", n)
+	}
+}
+
+func TestPubSubDedup(t *testing.T) {
+	type event struct {
+		ID   int
+		Body string
+	}
+
+	ps, err := New(8, 1, WithDedup(Dedup{
+		ID:   func(v interface{}) interface{} { return v.(event).ID },
+		Size: 16,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []interface{}{}
+	if _, err := ps.SubFunc(func(v interface{}) { got = append(got, v.(event).Body) }); err != nil {
+		t.Fatal(err)
+	}
+
+	ps.Pub(event{1, "A"})
+	ps.Pub(event{1, "A"})
+	ps.PubSlice([]interface{}{event{2, "B"}, event{1, "A"}, event{2, "B"}, event{3, "C"}})
+	ps.PubTTL(event{3, "C"}, time.Minute)
+	ps.Close()
+
+	if want := []interface{}{"A", "B", "C"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values %v, got %v", want, got)
+	}
+	if n := ps.Stats().Duplicates; n != 4 {
+		t.Errorf("want duplicates=4, got %d", n)
+	}
+}
+
+func TestPubSubDedupRateLimited(t *testing.T) {
+	clock := newFakeClock()
+	ps, err := New(8, 1, WithClock(clock), WithDedup(Dedup{
+		ID:   func(v interface{}) interface{} { return v },
+		Size: 16,
+	}), WithPubRateLimit(RateLimit{
+		Rate:   1,
+		Reject: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ps.Pub("A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Pub("B"); err != ErrRateLimited {
+		t.Fatalf("want err %q, got %v", ErrRateLimited, err)
+	}
+	if err := ps.PubSlice([]interface{}{"C"}); err != ErrRateLimited {
+		t.Fatalf("want err %q, got %v", ErrRateLimited, err)
+	}
+
+	clock.Advance(time.Second)
+	if err := ps.Pub("B"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if err := ps.PubSlice([]interface{}{"C"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := []interface{}{"A", "B", "C"}, ps.buffer.Read(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want buffer %v, got %v", want, got)
+	}
+	if n := ps.Stats().Duplicates; n != 0 {
+		t.Errorf("want duplicates=0, got %d", n)
+	}
+	ps.Close()
+}
+
+func TestPubChanDedup(t *testing.T) {
+	ps, err := New(8, 1, WithDedup(Dedup{
+		ID:   func(v interface{}) interface{} { return v },
+		Size: 16,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 3)
+	ch <- "A"
+	ch <- "A"
+	ch <- "B"
+	close(ch)
+
+	pub, err := ps.PubChan(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-pub.Done()
+
+	if n := pub.Published(); n != 2 {
+		t.Errorf("want 2 published values, got %d", n)
+	}
+	if n := pub.Dropped(); n != 1 {
+		t.Errorf("want 1 dropped value, got %d", n)
+	}
+	ps.Close()
+}
@@ -0,0 +1,8 @@
+// Package pubsub implements an in-process publish/subscribe broker on top of
+// a ring buffer. Publishers write values to a PubSub and every subscription
+// reads them in order, at its own pace.
+//
+// The module requires Go 1.22 or later. The proto codec depends on
+// google.golang.org/protobuf, which requires Go 1.22, and the admin handler
+// uses the method and wildcard patterns of http.ServeMux added in Go 1.22.
+package pubsub
@@ -27,15 +27,18 @@ func main() {
 	}
 
 	ch := make(chan interface{})
-	donec, _ := ps.PubChan(ch)
+	pub, _ := ps.PubChan(ch)
 	for i := 0; i <= 25; i++ {
 		select {
 		case ch <- i:
-		case <-donec:
-			panic("PubSub closed unexpectedly")
+		case <-pub.Done():
+			panic(pub.Err())
 		}
 	}
 	close(ch)
+
+	<-pub.Done()
+	fmt.Printf("published %d\n", pub.Published())
 	ps.Close()
 	wg.Wait()
 }
@@ -36,7 +36,7 @@ func (ch fooChan) PublishTo(ctx *pubsub.Context) error {
 
 func (ch fooChan) SubscribeTo(ctx *pubsub.Context) error {
 	rfn := func(v interface{}) bool {
-		if v == ctx.Done {
+		if v == ctx.Done || v == ctx.Unsub {
 			close(ch)
 			ctx.Close()
 			return false
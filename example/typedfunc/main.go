@@ -14,7 +14,7 @@ type fooFunc func(v foo)
 
 func (fn fooFunc) SubscribeTo(ctx *pubsub.Context) error {
 	rfn := func(v interface{}) bool {
-		if v == ctx.Done {
+		if v == ctx.Done || v == ctx.Unsub {
 			ctx.Close()
 			return false
 		}
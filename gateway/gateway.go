@@ -0,0 +1,265 @@
+// Package gateway streams the values of a PubSub to HTTP clients as
+// Server-Sent Events, and publishes and subscribes over WebSockets.
+//
+// Every event carries the sequence number of its value as its ID. A
+// WebSocket message starts with the ID and a newline, followed by the
+// encoded value. A client that reconnects with a Last-Event-ID header, or a
+// lastEventId query parameter, resumes after that value if the PubSub still
+// holds it.
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+)
+
+// SlowPolicy sets what happens when a client falls behind by more values
+// than its connection queues.
+type SlowPolicy int
+
+const (
+	// SlowDisconnect ends the connection of a slow client. The client
+	// can reconnect and resume from the last event it received.
+	SlowDisconnect SlowPolicy = iota
+	// SlowDrop discards values until the client catches up.
+	SlowDrop
+	// SlowBlock waits for the client, holding back publishers.
+	SlowBlock
+)
+
+// Handler is an http.Handler that serves WebSocket upgrade requests with a
+// two-way WebSocket connection, and every other request with a stream of
+// Server-Sent Events.
+type Handler struct {
+	ps *pubsub.PubSub
+
+	codec       codec.Codec
+	queue       int
+	policy      func(*http.Request) SlowPolicy
+	heartbeat   time.Duration
+	maxMessage  int64
+	checkOrigin func(*http.Request) bool
+
+	conns   atomic.Int64
+	dropped atomic.Uint64
+	slow    atomic.Uint64
+	skipped atomic.Uint64
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithCodec sets the codec of event data and WebSocket messages. It
+// defaults to codec.JSON. Server-Sent Events require a codec that encodes
+// values as UTF-8 text.
+func WithCodec(c codec.Codec) Option {
+	return func(h *Handler) {
+		h.codec = c
+	}
+}
+
+// WithQueue sets the number of values queued for each connection before
+// the client is slow. It defaults to 64.
+func WithQueue(n int) Option {
+	return func(h *Handler) {
+		h.queue = n
+	}
+}
+
+// WithSlowPolicy sets the SlowPolicy of every connection. It defaults to
+// SlowDisconnect.
+func WithSlowPolicy(p SlowPolicy) Option {
+	return WithSlowPolicyFunc(func(*http.Request) SlowPolicy { return p })
+}
+
+// WithSlowPolicyFunc sets the SlowPolicy of each connection from its
+// request.
+func WithSlowPolicyFunc(fn func(*http.Request) SlowPolicy) Option {
+	return func(h *Handler) {
+		h.policy = fn
+	}
+}
+
+// WithHeartbeat sends a comment to event streams, and a ping to WebSockets,
+// every d to keep idle connections open through proxies.
+func WithHeartbeat(d time.Duration) Option {
+	return func(h *Handler) {
+		h.heartbeat = d
+	}
+}
+
+// WithMaxMessage sets the largest WebSocket message a client can publish.
+// It defaults to 1MiB.
+func WithMaxMessage(n int64) Option {
+	return func(h *Handler) {
+		h.maxMessage = n
+	}
+}
+
+// WithCheckOrigin sets the function that accepts the Origin of WebSocket
+// requests. By default only requests without an Origin header, or from the
+// same host, are accepted.
+func WithCheckOrigin(fn func(*http.Request) bool) Option {
+	return func(h *Handler) {
+		h.checkOrigin = fn
+	}
+}
+
+// New returns a Handler for ps.
+func New(ps *pubsub.PubSub, opts ...Option) *Handler {
+	h := &Handler{
+		ps:          ps,
+		codec:       codec.JSON,
+		queue:       64,
+		policy:      func(*http.Request) SlowPolicy { return SlowDisconnect },
+		maxMessage:  1 << 20,
+		checkOrigin: sameOrigin,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ServeHTTP subscribes the client to the PubSub until the request ends.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if headerContains(r.Header, "Upgrade", "websocket") {
+		h.serveWebSocket(w, r)
+	} else {
+		h.serveEvents(w, r)
+	}
+}
+
+// Stats are counters describing a Handler.
+type Stats struct {
+	// Conns is the number of open connections.
+	Conns int64
+	// Dropped is the number of values discarded by SlowDrop.
+	Dropped uint64
+	// Slow is the number of connections ended by SlowDisconnect.
+	Slow uint64
+	// Skipped is the number of values the codec failed to encode.
+	Skipped uint64
+}
+
+// Stats returns counters describing the Handler.
+func (h *Handler) Stats() Stats {
+	return Stats{
+		Conns:   h.conns.Load(),
+		Dropped: h.dropped.Load(),
+		Slow:    h.slow.Load(),
+		Skipped: h.skipped.Load(),
+	}
+}
+
+// subscribe subscribes a new connection for r. The returned function
+// unsubscribes it.
+func (h *Handler) subscribe(r *http.Request, seq int64) (*conn, func(), error) {
+	c := &conn{
+		h:      h,
+		seq:    seq,
+		policy: h.policy(r),
+		events: make(chan event, h.queue),
+		gone:   make(chan struct{}),
+	}
+
+	sub, err := h.ps.AddSubscriber(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.conns.Add(1)
+	return c, func() {
+		h.conns.Add(-1)
+		close(c.gone)
+		sub.Unsubscribe()
+	}, nil
+}
+
+// resumeSeq returns the sequence number after the event ID sent by the
+// client, or the write sequence for new clients.
+func resumeSeq(r *http.Request) (int64, error) {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("lastEventId")
+	}
+	if id == "" {
+		return 1<<63 - 1, nil // clamped to the write sequence
+	}
+
+	seq, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, errEventID
+	}
+	return seq + 1, nil
+}
+
+// event is a value and its sequence number.
+type event struct {
+	seq int64
+	v   interface{}
+}
+
+// conn is the Subscriber of a connection. It queues values for the
+// connection and applies its SlowPolicy once the queue is full.
+type conn struct {
+	h      *Handler
+	seq    int64
+	policy SlowPolicy
+
+	// events is closed once the subscription stops, after slow is set.
+	events chan event
+	gone   chan struct{}
+	slow   bool
+}
+
+func (c *conn) SubscribeTo(ctx *pubsub.Context) error {
+	stop := func() bool {
+		close(c.events)
+		ctx.Close()
+		return false
+	}
+
+	rfn := func(seq int64, v interface{}) bool {
+		if _, ok := v.(pubsub.MarkerChan); ok {
+			if v != ctx.Done && v != ctx.Unsub {
+				return true
+			}
+			return stop()
+		}
+
+		ev := event{seq: seq, v: v}
+		if c.policy == SlowBlock {
+			select {
+			case c.events <- ev:
+				return true
+			case <-c.gone:
+				return stop()
+			}
+		}
+
+		select {
+		case c.events <- ev:
+			return true
+		case <-c.gone:
+			return stop()
+		default:
+		}
+
+		if c.policy == SlowDrop {
+			c.h.dropped.Add(1)
+			return true
+		}
+		c.h.slow.Add(1)
+		c.slow = true
+		return stop()
+	}
+
+	ctx.Track(ctx.Buffer.ReadFromSeq(c.seq, rfn))
+	return nil
+}
@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benburkert/pubsub"
+)
+
+func TestSlowPolicy(t *testing.T) {
+	tests := []struct {
+		policy      SlowPolicy
+		wantEvents  int
+		wantDropped uint64
+		wantSlow    uint64
+	}{
+		{SlowDisconnect, 1, 0, 1},
+		{SlowDrop, 1, 2, 0},
+	}
+
+	for _, test := range tests {
+		ps, err := pubsub.New(8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h := New(ps, WithQueue(1), WithSlowPolicy(test.policy))
+		c, unsub, err := h.subscribe(httptest.NewRequest("GET", "/", nil), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ps.Pub("A")
+		ps.Pub("B")
+		ps.Pub("C")
+		ps.Close()
+
+		n := 0
+		for range c.events {
+			n++
+		}
+		unsub()
+
+		if want, got := test.wantEvents, n; want != got {
+			t.Errorf("policy %d: want %d events, got %d", test.policy, want, got)
+		}
+		if want, got := (Stats{Dropped: test.wantDropped, Slow: test.wantSlow}), h.Stats(); want != got {
+			t.Errorf("policy %d: want stats %+v, got %+v", test.policy, want, got)
+		}
+	}
+}
+
+func TestSlowBlock(t *testing.T) {
+	ps, err := pubsub.New(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(ps, WithQueue(1), WithSlowPolicy(SlowBlock))
+	c, unsub, err := h.subscribe(httptest.NewRequest("GET", "/", nil), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for _, v := range []string{"A", "B", "C", "D"} {
+			ps.Pub(v)
+		}
+		ps.Close()
+	}()
+
+	got := []interface{}{}
+	for ev := range c.events {
+		got = append(got, ev.v)
+	}
+	unsub()
+
+	if want := 4; len(got) != want {
+		t.Errorf("want %d events, got %v", want, got)
+	}
+}
+
+func TestResumeSeq(t *testing.T) {
+	tests := []struct {
+		header, query string
+		want          int64
+		wantErr       bool
+	}{
+		{"", "", 1<<63 - 1, false},
+		{"4", "", 5, false},
+		{"", "7", 8, false},
+		{"x", "", 0, true},
+		{"-1", "", 0, true},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/?lastEventId="+test.query, nil)
+		if test.header != "" {
+			r.Header.Set("Last-Event-ID", test.header)
+		}
+
+		seq, err := resumeSeq(r)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q/%q: want error %t, got %v", test.header, test.query, test.wantErr, err)
+		}
+		if err == nil && seq != test.want {
+			t.Errorf("%q/%q: want seq %d, got %d", test.header, test.query, test.want, seq)
+		}
+	}
+}
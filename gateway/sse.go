@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	errEventID = errors.New("gateway: invalid event ID")
+	errNoFlush = errors.New("gateway: response does not support flushing")
+)
+
+// serveEvents streams values to the client as Server-Sent Events.
+func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request) {
+	seq, err := resumeSeq(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, errNoFlush.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c, unsub, err := h.subscribe(r, seq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer unsub()
+
+	hdr := w.Header()
+	hdr.Set("Content-Type", "text/event-stream")
+	hdr.Set("Cache-Control", "no-cache")
+	hdr.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	var tickc <-chan time.Time
+	if h.heartbeat > 0 {
+		t := time.NewTicker(h.heartbeat)
+		defer t.Stop()
+		tickc = t.C
+	}
+
+	var buf bytes.Buffer
+	for {
+		select {
+		case ev, ok := <-c.events:
+			if !ok {
+				return
+			}
+
+			buf.Reset()
+			h.appendEvent(&buf, ev)
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return
+			}
+			if len(c.events) == 0 {
+				f.Flush()
+			}
+		case <-tickc:
+			if _, err := w.Write([]byte(":\n\n")); err != nil {
+				return
+			}
+			f.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// appendEvent writes ev to buf in the event stream format. A value the codec
+// can not encode is sent as an event without data, which clients do not
+// dispatch but which still moves their last event ID forward.
+func (h *Handler) appendEvent(buf *bytes.Buffer, ev event) {
+	buf.WriteString("id: ")
+	buf.WriteString(strconv.FormatInt(ev.seq, 10))
+	buf.WriteByte('\n')
+
+	data, err := h.codec.Marshal(ev.v)
+	if err != nil {
+		h.skipped.Add(1)
+		buf.WriteByte('\n')
+		return
+	}
+
+	for {
+		line := data
+		i := bytes.IndexByte(data, '\n')
+		if i >= 0 {
+			line, data = data[:i], data[i+1:]
+		}
+
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte{'\r'}))
+		buf.WriteByte('\n')
+
+		if i < 0 {
+			break
+		}
+	}
+	buf.WriteByte('\n')
+}
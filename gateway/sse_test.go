@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+)
+
+func TestEvents(t *testing.T) {
+	ps, err := pubsub.New(8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	srv := httptest.NewServer(New(ps))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "text/event-stream", resp.Header.Get("Content-Type"); want != got {
+		t.Errorf("want content type %q, got %q", want, got)
+	}
+
+	ps.Pub("one")
+	ps.Pub(map[string]int{"two": 2})
+
+	br := bufio.NewReader(resp.Body)
+	id1, data := readEvent(t, br)
+	if want, got := `"one"`, data; want != got {
+		t.Errorf("want data %q, got %q", want, got)
+	}
+	if _, data := readEvent(t, br); data != `{"two":2}` {
+		t.Errorf("want data %q, got %q", `{"two":2}`, data)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Last-Event-ID", id1)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if _, data := readEvent(t, bufio.NewReader(resp.Body)); data != `{"two":2}` {
+		t.Errorf("want resumed data %q, got %q", `{"two":2}`, data)
+	}
+}
+
+func TestEventsBadID(t *testing.T) {
+	ps, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/?lastEventId=x", nil)
+	New(ps).ServeHTTP(w, r)
+
+	if want, got := http.StatusBadRequest, w.Code; want != got {
+		t.Errorf("want status %d, got %d", want, got)
+	}
+}
+
+func TestAppendEvent(t *testing.T) {
+	tests := []struct {
+		codec codec.Codec
+		v     interface{}
+		want  string
+	}{
+		{codec.Raw, "A", "id: 3\ndata: A\n\n"},
+		{codec.Raw, "A\nB\r\n", "id: 3\ndata: A\ndata: B\ndata: \n\n"},
+		{codec.JSON, make(chan int), "id: 3\n\n"},
+	}
+
+	for _, test := range tests {
+		h := New(nil, WithCodec(test.codec))
+
+		var buf bytes.Buffer
+		h.appendEvent(&buf, event{seq: 3, v: test.v})
+		if got := buf.String(); test.want != got {
+			t.Errorf("want event %q, got %q", test.want, got)
+		}
+	}
+}
+
+// readEvent returns the ID and data of the next event from br.
+func readEvent(t *testing.T, br *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var id string
+	var data []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if data != nil {
+				return id, strings.Join(data, "\n")
+			}
+		case strings.HasPrefix(line, "id: "):
+			id = line[4:]
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, line[6:])
+		}
+	}
+}
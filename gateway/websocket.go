@@ -0,0 +1,374 @@
+package gateway
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benburkert/pubsub"
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes.
+const (
+	opContinue = 0x0
+	opText     = 0x1
+	opBinary   = 0x2
+	opClose    = 0x8
+	opPing     = 0x9
+	opPong     = 0xa
+)
+
+// WebSocket close status codes.
+const (
+	closeNormal    = 1000
+	closeGoingAway = 1001
+	closeProtocol  = 1002
+	closeInvalid   = 1007
+	closePolicy    = 1008
+	closeTooBig    = 1009
+	closeInternal  = 1011
+)
+
+// maxControlLength is the largest payload of a control frame.
+const maxControlLength = 125
+
+// writeTimeout is the longest a frame write waits for a client.
+const writeTimeout = 10 * time.Second
+
+var (
+	errBadRequest = errors.New("gateway: not a WebSocket handshake")
+	errOrigin     = errors.New("gateway: origin not allowed")
+	errProtocol   = errors.New("gateway: WebSocket protocol error")
+	errTooBig     = errors.New("gateway: WebSocket message too large")
+	errInvalid    = errors.New("gateway: invalid WebSocket message")
+)
+
+// serveWebSocket upgrades the request to a WebSocket. Values published to
+// the PubSub are sent to the client as messages of their event ID and
+// encoded value, separated by a newline, and messages from the client are
+// decoded and published.
+func (h *Handler) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	seq, err := resumeSeq(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkHandshake(w, r) {
+		return
+	}
+
+	// subscribe before the handshake completes, so the client receives
+	// every value published after it connects.
+	c, unsub, err := h.subscribe(r, seq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer unsub()
+
+	ws, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.nc.Close()
+
+	readc := make(chan error, 1)
+	go func() { readc <- h.readMessages(ws) }()
+
+	var tickc <-chan time.Time
+	if h.heartbeat > 0 {
+		t := time.NewTicker(h.heartbeat)
+		defer t.Stop()
+		tickc = t.C
+	}
+
+	op := byte(opBinary)
+	if h.codec.Name() == "json" {
+		op = opText
+	}
+
+	for {
+		select {
+		case ev, ok := <-c.events:
+			if !ok {
+				if c.slow {
+					ws.writeClose(closePolicy, "slow client")
+				} else {
+					ws.writeClose(closeGoingAway, "")
+				}
+				return
+			}
+
+			data, err := h.codec.Marshal(ev.v)
+			if err != nil {
+				h.skipped.Add(1)
+				continue
+			}
+			msg := strconv.AppendInt(nil, ev.seq, 10)
+			msg = append(append(msg, '\n'), data...)
+			if err := ws.writeFrame(op, msg); err != nil {
+				return
+			}
+		case <-tickc:
+			if err := ws.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		case err := <-readc:
+			switch {
+			case err == io.EOF:
+				ws.writeClose(closeNormal, "")
+			case errors.Is(err, errTooBig):
+				ws.writeClose(closeTooBig, "")
+			case errors.Is(err, errProtocol):
+				ws.writeClose(closeProtocol, "")
+			case errors.Is(err, errInvalid):
+				ws.writeClose(closeInvalid, err.Error())
+			case err != nil:
+				ws.writeClose(closeInternal, err.Error())
+			}
+			return
+		}
+	}
+}
+
+// readMessages publishes the messages of ws until the client closes it. It
+// returns io.EOF after a close frame.
+func (h *Handler) readMessages(ws *wsConn) error {
+	for {
+		data, err := ws.readMessage(h.maxMessage)
+		if err != nil {
+			return err
+		}
+
+		var v interface{}
+		if err := h.codec.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("%w: %w", errInvalid, err)
+		}
+		if err := h.ps.Pub(v); err != nil && !errors.Is(err, pubsub.ErrRateLimited) {
+			return err
+		}
+	}
+}
+
+// checkHandshake reports whether r is a valid WebSocket handshake from an
+// accepted origin, and replies with an error if it is not.
+func (h *Handler) checkHandshake(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") || r.Header.Get("Sec-WebSocket-Key") == "" {
+		http.Error(w, errBadRequest.Error(), http.StatusBadRequest)
+		return false
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, errBadRequest.Error(), http.StatusUpgradeRequired)
+		return false
+	}
+	if !h.checkOrigin(r) {
+		http.Error(w, errOrigin.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// upgrade completes the WebSocket handshake of r and takes over its
+// connection.
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, http.ErrNotSupported.Error(), http.StatusInternalServerError)
+		return nil, http.ErrNotSupported
+	}
+	nc, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	brw.WriteString("Upgrade: websocket\r\n")
+	brw.WriteString("Connection: Upgrade\r\n")
+	brw.WriteString("Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return &wsConn{nc: nc, br: brw.Reader, bw: brw.Writer}, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsConn is the server side of a WebSocket connection. Frames are written
+// by any goroutine, and read by one.
+type wsConn struct {
+	nc net.Conn
+	br *bufio.Reader
+
+	wmu sync.Mutex
+	bw  *bufio.Writer
+}
+
+// writeFrame writes an unfragmented frame, giving up after writeTimeout.
+func (ws *wsConn) writeFrame(op byte, data []byte) error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+
+	if err := ws.nc.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+
+	hdr := make([]byte, 2, 10)
+	hdr[0] = 0x80 | op
+	switch n := len(data); {
+	case n <= 125:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+
+	ws.bw.Write(hdr)
+	ws.bw.Write(data)
+	return ws.bw.Flush()
+}
+
+func (ws *wsConn) writeClose(code int, reason string) error {
+	if len(reason) > maxControlLength-2 {
+		reason = reason[:maxControlLength-2]
+	}
+
+	data := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return ws.writeFrame(opClose, append(data, reason...))
+}
+
+// readMessage returns the data of the next text or binary message. It
+// answers pings, and returns io.EOF once the client sends a close frame.
+func (ws *wsConn) readMessage(max int64) ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, op, data, err := ws.readFrame(max - int64(len(msg)))
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := ws.writeFrame(opPong, data); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				return nil, errProtocol
+			}
+			started = true
+		case opContinue:
+			if !started {
+				return nil, errProtocol
+			}
+		default:
+			return nil, errProtocol
+		}
+
+		msg = append(msg, data...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads one frame of at most max bytes from the client and
+// unmasks it.
+func (ws *wsConn) readFrame(max int64) (bool, byte, []byte, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(ws.br, hdr[:2]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin, op := hdr[0]&0x80 != 0, hdr[0]&0x0f
+	if hdr[0]&0x70 != 0 || hdr[1]&0x80 == 0 {
+		return false, 0, nil, errProtocol // reserved bits, or unmasked
+	}
+
+	n := int64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		if _, err := io.ReadFull(ws.br, hdr[:2]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint16(hdr[:2]))
+	case 127:
+		if _, err := io.ReadFull(ws.br, hdr[:8]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint64(hdr[:8]))
+	}
+
+	if op >= opClose && (!fin || n > maxControlLength) {
+		return false, 0, nil, errProtocol
+	}
+	if op < opClose && (n < 0 || n > max) {
+		return false, 0, nil, errTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(ws.br, data); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+	return fin, op, data, nil
+}
+
+// headerContains reports whether a comma separated header contains token,
+// ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin accepts requests without an Origin header, or with an Origin
+// of the requested host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,232 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benburkert/pubsub"
+)
+
+func TestWebSocket(t *testing.T) {
+	ps, err := pubsub.New(8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	srv := httptest.NewServer(New(ps))
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv, nil)
+	defer c.nc.Close()
+
+	ps.Pub("one")
+	if op, data := c.read(t); op != opText || string(data) != "0\n\"one\"" {
+		t.Errorf("want text message %q, got %d %q", "0\n\"one\"", op, data)
+	}
+
+	// the message is published and sent back to the subscribed client.
+	c.write(t, opText, []byte(`"two"`), false)
+	if _, data := c.read(t); string(data) != "1\n\"two\"" {
+		t.Errorf("want message %q, got %q", "1\n\"two\"", data)
+	}
+
+	c.write(t, opPing, []byte("hi"), false)
+	if op, data := c.read(t); op != opPong || string(data) != "hi" {
+		t.Errorf("want pong %q, got %d %q", "hi", op, data)
+	}
+
+	// fragmented message.
+	c.write(t, opText, []byte(`"thr`), true)
+	c.write(t, opContinue, []byte(`ee"`), false)
+	if _, data := c.read(t); string(data) != "2\n\"three\"" {
+		t.Errorf("want message %q, got %q", "2\n\"three\"", data)
+	}
+
+	c.write(t, opClose, binary.BigEndian.AppendUint16(nil, closeNormal), false)
+	op, data := c.read(t)
+	if op != opClose || binary.BigEndian.Uint16(data) != closeNormal {
+		t.Errorf("want close %d, got %d %q", closeNormal, op, data)
+	}
+}
+
+func TestWebSocketResume(t *testing.T) {
+	ps, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps.Pub("A")
+	ps.Pub("B")
+	ps.Pub("C")
+
+	srv := httptest.NewServer(New(ps))
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv, map[string]string{"lastEventId": "0"})
+	defer c.nc.Close()
+
+	for _, want := range []string{"1\n\"B\"", "2\n\"C\""} {
+		if _, data := c.read(t); string(data) != want {
+			t.Errorf("want message %q, got %q", want, data)
+		}
+	}
+
+	ps.Close()
+	op, data := c.read(t)
+	if op != opClose || binary.BigEndian.Uint16(data) != closeGoingAway {
+		t.Errorf("want close %d, got %d %q", closeGoingAway, op, data)
+	}
+}
+
+func TestWebSocketInvalidMessage(t *testing.T) {
+	ps, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	srv := httptest.NewServer(New(ps))
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv, nil)
+	defer c.nc.Close()
+
+	c.write(t, opText, []byte("not json"), false)
+	op, data := c.read(t)
+	if op != opClose || binary.BigEndian.Uint16(data) != closeInvalid {
+		t.Errorf("want close %d, got %d %q", closeInvalid, op, data)
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	ps, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	tests := []struct {
+		header map[string]string
+		want   int
+	}{
+		{map[string]string{"Sec-WebSocket-Key": ""}, http.StatusBadRequest},
+		{map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		for k, v := range test.header {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		New(ps).ServeHTTP(w, r)
+		if test.want != w.Code {
+			t.Errorf("%v: want status %d, got %d", test.header, test.want, w.Code)
+		}
+	}
+
+	if want, got := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); want != got {
+		t.Errorf("want accept key %q, got %q", want, got)
+	}
+}
+
+// wsClient is a minimal WebSocket client.
+type wsClient struct {
+	nc net.Conn
+	br *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, query map[string]string) *wsClient {
+	t.Helper()
+
+	nc, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	q := req.URL.Query()
+	for k, v := range query {
+		q.Set(k, v)
+	}
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(nc); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("want status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		t.Fatalf("want websocket upgrade, got %q", resp.Header.Get("Upgrade"))
+	}
+	return &wsClient{nc: nc, br: br}
+}
+
+// write sends a masked frame. more leaves the FIN bit clear.
+func (c *wsClient) write(t *testing.T, op byte, data []byte, more bool) {
+	t.Helper()
+
+	b0 := op
+	if !more {
+		b0 |= 0x80
+	}
+
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{b0, 0x80 | byte(len(data))}
+	frame = append(frame, mask[:]...)
+	for i, b := range data {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.nc.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// read returns the next unfragmented frame from the server.
+func (c *wsClient) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+
+	var hdr [8]byte
+	if _, err := io.ReadFull(c.br, hdr[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	n := int(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		io.ReadFull(c.br, hdr[:2])
+		n = int(binary.BigEndian.Uint16(hdr[:2]))
+	case 127:
+		io.ReadFull(c.br, hdr[:8])
+		n = int(binary.BigEndian.Uint64(hdr[:8]))
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.br, data); err != nil {
+		t.Fatal(err)
+	}
+	return hdr[0] & 0x0f, data
+}
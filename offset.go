@@ -0,0 +1,229 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OffsetStore holds the committed position of named subscriptions. A named
+// subscription resumes from its committed position when it subscribes
+// again, as long as the ring still holds the values after it.
+//
+// Positions are sequence numbers of the ring, which start over at 0 in a
+// new PubSub. A store that outlives the process, such as the file store or
+// boltstore, only resumes subscriptions in a PubSub restored with Restore
+// from a Snapshot taken after the positions were committed. Without a
+// restored snapshot, a stored position refers to the values of an earlier
+// process: it is clamped to the new ring, and a subscription may skip or
+// repeat values.
+type OffsetStore interface {
+	// Load returns the committed sequence number of name. ok is false if
+	// name has never been committed.
+	Load(name string) (seq int64, ok bool, err error)
+	// Store commits seq as the position of name.
+	Store(name string, seq int64) error
+}
+
+// NewMemoryOffsetStore returns an OffsetStore that keeps offsets in memory.
+// It is the default OffsetStore of a PubSub.
+func NewMemoryOffsetStore() OffsetStore {
+	return &memoryOffsetStore{offsets: make(map[string]int64)}
+}
+
+type memoryOffsetStore struct {
+	mu      sync.Mutex
+	offsets map[string]int64
+}
+
+func (s *memoryOffsetStore) Load(name string) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq, ok := s.offsets[name]
+	return seq, ok, nil
+}
+
+func (s *memoryOffsetStore) Store(name string, seq int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offsets[name] = seq
+	return nil
+}
+
+// NewFileOffsetStore returns an OffsetStore that keeps offsets in a JSON
+// file at path. The file is replaced atomically on every commit. The
+// offsets only resume subscriptions after a restart together with
+// Snapshot and Restore, see OffsetStore.
+func NewFileOffsetStore(path string) (OffsetStore, error) {
+	s := &fileOffsetStore{
+		path:    path,
+		offsets: make(map[string]int64),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.offsets); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type fileOffsetStore struct {
+	mu      sync.Mutex
+	path    string
+	offsets map[string]int64
+}
+
+func (s *fileOffsetStore) Load(name string) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq, ok := s.offsets[name]
+	return seq, ok, nil
+}
+
+func (s *fileOffsetStore) Store(name string, seq int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offsets[name] = seq
+
+	data, err := json.Marshal(s.offsets)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// CommitError is the error of the last commit of a named subscription.
+type CommitError struct {
+	Name string
+	Err  error
+}
+
+// MarshalJSON encodes the error as its message.
+func (ce CommitError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct{ Name, Err string }{ce.Name, ce.Err.Error()})
+}
+
+// namedSub is the state of a named subscription.
+type namedSub struct {
+	name   string
+	manual bool
+
+	r         *Reader
+	delivered atomic.Int64 // sequence after the last value handed over, or -1
+	handled   atomic.Int64 // sequence after the last value handled, or -1
+
+	// pending is set while an automatic commit is scheduled.
+	pending atomic.Bool
+
+	// mu serializes commits so an older position never replaces a newer
+	// one.
+	mu        sync.Mutex
+	committed int64
+	err       error // error of the last commit
+}
+
+func newNamedSub(cfg *subConfig) *namedSub {
+	ns := &namedSub{
+		name:      cfg.name,
+		manual:    cfg.manualCommit,
+		committed: -1,
+	}
+	ns.delivered.Store(-1)
+	ns.handled.Store(-1)
+	return ns
+}
+
+func (ns *namedSub) active() bool {
+	return ns.r != nil && !ns.r.done.Load()
+}
+
+// commit stores the position after the values handed over.
+func (ns *namedSub) commit(s OffsetStore) error {
+	return ns.store(s, &ns.delivered)
+}
+
+// autoCommit stores the position after the values handled.
+func (ns *namedSub) autoCommit(s OffsetStore) error {
+	return ns.store(s, &ns.handled)
+}
+
+func (ns *namedSub) store(s OffsetStore, pos *atomic.Int64) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	seq := pos.Load()
+	if seq < 0 || (seq == ns.committed && ns.err == nil) {
+		return nil
+	}
+
+	ns.err = s.Store(ns.name, seq)
+	if ns.err == nil {
+		ns.committed = seq
+	}
+	return ns.err
+}
+
+// scheduleCommit commits the handled values after d, outside of the
+// reading goroutine. Values handled before then are committed together.
+func (ns *namedSub) scheduleCommit(c Clock, d time.Duration, s OffsetStore) {
+	if ns.pending.CompareAndSwap(false, true) {
+		c.AfterFunc(d, func() {
+			ns.pending.Store(false)
+			ns.autoCommit(s)
+		})
+	}
+}
+
+// commitErrors returns the failed last commits ordered by name.
+func (ps *PubSub) commitErrors() []CommitError {
+	ps.namemu.Lock()
+	defer ps.namemu.Unlock()
+
+	var errs []CommitError
+	for name, ns := range ps.named {
+		if err := ns.commitErr(); err != nil {
+			errs = append(errs, CommitError{Name: name, Err: err})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Name < errs[j].Name })
+	return errs
+}
+
+func (ns *namedSub) commitErr() error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	return ns.err
+}
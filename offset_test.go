@@ -0,0 +1,156 @@
+package pubsub
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileOffsetStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.json")
+
+	s, err := NewFileOffsetStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := s.Load("a"); err != nil || ok {
+		t.Fatalf("want no offset, got ok=%t err=%v", ok, err)
+	}
+	if err := s.Store("a", 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store("b", 7); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err = NewFileOffsetStore(path); err != nil {
+		t.Fatal(err)
+	}
+	if seq, ok, err := s.Load("a"); err != nil || !ok || seq != 42 {
+		t.Errorf("want offset 42, got %d ok=%t err=%v", seq, ok, err)
+	}
+	if seq, ok, err := s.Load("b"); err != nil || !ok || seq != 7 {
+		t.Errorf("want offset 7, got %d ok=%t err=%v", seq, ok, err)
+	}
+}
+
+func TestDurableSubscription(t *testing.T) {
+	ps, err := New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	got := []interface{}{}
+	subscribe := func(n int, pub []interface{}, opts ...SubOption) {
+		donec := make(chan struct{})
+		sub, err := ps.SubFunc(func(v interface{}) {
+			got = append(got, v)
+			if v == "C" {
+				if err := ps.Commit("a"); err != nil {
+					t.Error(err)
+				}
+			}
+			if len(got) == n {
+				close(donec)
+			}
+		}, append(opts, WithName("a"))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ps.PubSlice(pub)
+		<-donec
+		sub.Unsubscribe()
+		<-sub.Done()
+	}
+
+	subscribe(2, []interface{}{"A", "B"})
+	ps.PubSlice([]interface{}{"C", "D"})
+	subscribe(4, nil, WithManualCommit())
+	subscribe(6, []interface{}{"E"})
+
+	if want := []interface{}{"A", "B", "C", "D", "D", "E"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values %v, got %v", want, got)
+	}
+	if err := ps.Commit("b"); err != errNoName {
+		t.Errorf("unexpected error %q", err)
+	}
+}
+
+// countStore counts the commits of a MemoryOffsetStore and fails them once
+// err is set.
+type countStore struct {
+	OffsetStore
+
+	mu     sync.Mutex
+	stores int
+	err    error
+}
+
+func (s *countStore) Store(name string, seq int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stores++
+	if s.err != nil {
+		return s.err
+	}
+	return s.OffsetStore.Store(name, seq)
+}
+
+func TestCommitInterval(t *testing.T) {
+	clock := newFakeClock()
+	store := &countStore{OffsetStore: NewMemoryOffsetStore()}
+	ps, err := New(8, 1, WithClock(clock), WithOffsetStore(store), WithCommitInterval(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	sub, err := ps.SubFunc(func(interface{}) { wg.Done() }, WithName("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps.PubSlice([]interface{}{"A", "B", "C"})
+	wg.Wait()
+
+	waitPending(clock, 1)
+	clock.Advance(time.Second)
+	if seq, _, _ := store.Load("a"); seq != 3 || store.stores != 1 {
+		t.Errorf("want one commit of offset 3, got %d commits of offset %d", store.stores, seq)
+	}
+
+	store.mu.Lock()
+	store.err = errors.New("store failed")
+	store.mu.Unlock()
+
+	wg.Add(1)
+	ps.Pub("D")
+	wg.Wait()
+	waitPending(clock, 1)
+	clock.Advance(time.Second)
+
+	want := []CommitError{{Name: "a", Err: store.err}}
+	if errs := ps.Stats().CommitErrors; !reflect.DeepEqual(want, errs) {
+		t.Errorf("want commit errors %v, got %v", want, errs)
+	}
+
+	store.mu.Lock()
+	store.err = nil
+	store.mu.Unlock()
+
+	sub.Unsubscribe()
+	<-sub.Done()
+	if seq, _, _ := store.Load("a"); seq != 4 {
+		t.Errorf("want offset 4 committed on unsubscribe, got %d", seq)
+	}
+	if errs := ps.Stats().CommitErrors; len(errs) != 0 {
+		t.Errorf("want no commit errors, got %v", errs)
+	}
+}
@@ -0,0 +1,160 @@
+package pubsub
+
+import "time"
+
+// Option configures a PubSub.
+type Option func(*PubSub)
+
+// WithClock sets the Clock used for time dependent features.
+func WithClock(c Clock) Option {
+	return func(ps *PubSub) {
+		ps.clock = c
+	}
+}
+
+// WithTTL sets the default time to live of published values. Subscribers
+// skip values that have been in the buffer for longer than d.
+func WithTTL(d time.Duration) Option {
+	return func(ps *PubSub) {
+		ps.buffer.ttl = d
+	}
+}
+
+// WithExpiry sets a callback that is called once for every value that
+// expires before a subscriber reads it. The callback runs on the goroutine
+// of the subscriber that skips the value and, like a subscriber callback,
+// must not publish to the same PubSub. A value that expires while it is
+// spilled for a WithSpill subscription is passed to the callback on the
+// publishing goroutine once the write completes.
+func WithExpiry(fn func(interface{})) Option {
+	return func(ps *PubSub) {
+		ps.buffer.onExpire = fn
+	}
+}
+
+// WithDeadLetter publishes expired values to dl, which must be a different
+// PubSub.
+func WithDeadLetter(dl *PubSub) Option {
+	return WithExpiry(func(v interface{}) {
+		dl.Pub(v)
+	})
+}
+
+// WithOffsetStore sets the OffsetStore for named subscriptions. The default
+// keeps offsets in memory.
+func WithOffsetStore(s OffsetStore) Option {
+	return func(ps *PubSub) {
+		ps.offsets = s
+	}
+}
+
+// WithCommitInterval sets how often named subscriptions commit the values
+// they handled, see Commit. The default is 100ms.
+func WithCommitInterval(d time.Duration) Option {
+	return func(ps *PubSub) {
+		ps.commitInterval = d
+	}
+}
+
+// WithDedup drops published values whose ID was already published within
+// the window described by d.
+func WithDedup(d Dedup) Option {
+	return func(ps *PubSub) {
+		ps.dedup = newDedupSet(d)
+	}
+}
+
+// WithStallDetector reports publishers that wait for longer than
+// sd.Threshold on a full buffer to sd.Hook.
+func WithStallDetector(sd StallDetector) Option {
+	return func(ps *PubSub) {
+		ps.stall = sd
+	}
+}
+
+// WithPubRateLimit rate limits publishing with a token bucket. The limit
+// applies to Pub, PubTTL, PubSlice, PubChan, scheduled values and the
+// publishers added with AddPublisher. Values over a limit that rejects are
+// dropped where there is no error to return.
+func WithPubRateLimit(rl RateLimit) Option {
+	return func(ps *PubSub) {
+		ps.publimit = &rl
+	}
+}
+
+// SubOption configures a subscription.
+type SubOption func(*subConfig)
+
+type subConfig struct {
+	name         string
+	manualCommit bool
+	limit        *RateLimit
+	pausePolicy  PausePolicy
+	overflow     Overflow
+
+	spill          bool
+	spillThreshold int
+}
+
+func newSubConfig(opts []SubOption) *subConfig {
+	cfg := &subConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithName names a subscription. Only one active subscription can use a
+// name. A named subscription commits its position to the OffsetStore and
+// continues from the committed position when it subscribes again, also after
+// a snapshot is restored.
+func WithName(name string) SubOption {
+	return func(cfg *subConfig) {
+		cfg.name = name
+	}
+}
+
+// WithManualCommit stops a named subscription from committing after every
+// value. Its position is only committed by PubSub.Commit.
+func WithManualCommit() SubOption {
+	return func(cfg *subConfig) {
+		cfg.manualCommit = true
+	}
+}
+
+// WithSubRateLimit paces the delivery of values to a subscription with a
+// token bucket. Delivery waits on the subscriber goroutine, the same as a
+// slow handler.
+func WithSubRateLimit(rl RateLimit) SubOption {
+	return func(cfg *subConfig) {
+		cfg.limit = &rl
+	}
+}
+
+// WithPausePolicy sets what happens to the values published while the
+// subscription is paused. The default is PauseBlock.
+func WithPausePolicy(p PausePolicy) SubOption {
+	return func(cfg *subConfig) {
+		cfg.pausePolicy = p
+	}
+}
+
+// WithOverflow sets the Overflow that a subscription spills values to. The
+// default keeps them in memory.
+func WithOverflow(o Overflow) SubOption {
+	return func(cfg *subConfig) {
+		cfg.overflow = o
+	}
+}
+
+// WithSpill stops a lagging subscription from holding back publishers. Once
+// it lags more than threshold values behind, publishers move the values it
+// has not read to its Overflow. The subscription reads them back before it
+// rejoins the values in the buffer. Its handler runs without holding the
+// buffer lock, so publishers do not wait for it.
+func WithSpill(threshold int) SubOption {
+	return func(cfg *subConfig) {
+		cfg.spill = true
+		cfg.spillThreshold = threshold
+	}
+}
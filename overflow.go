@@ -0,0 +1,231 @@
+package pubsub
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/benburkert/pubsub/codec"
+)
+
+// PausePolicy decides what happens to the values published while a
+// subscription is paused.
+type PausePolicy int
+
+const (
+	// PauseBlock keeps values in the buffer. Publishers block once the
+	// buffer is full, as if the subscription were slow.
+	PauseBlock PausePolicy = iota
+
+	// PauseSpill moves values to the Overflow of the subscription. They
+	// are delivered when the subscription resumes, before any value that is
+	// still in the buffer.
+	PauseSpill
+
+	// PauseDrop discards values. They are counted by Dropped.
+	PauseDrop
+)
+
+// Overflow is a FIFO queue of values moved out of the buffer for a single
+// subscription. It must be safe for concurrent use by one pushing or
+// popping goroutine and any number of Len callers.
+type Overflow interface {
+	// Push appends v, published with sequence number seq.
+	Push(seq int64, v interface{}) error
+	// Pop removes and returns the oldest value. ok is false if the queue
+	// is empty. The value is removed even if it can not be returned.
+	Pop() (seq int64, v interface{}, ok bool, err error)
+	// Len returns the number of queued values.
+	Len() int
+}
+
+// NewMemoryOverflow returns an unbounded Overflow that keeps values in
+// memory. It is the default Overflow of subscriptions that spill.
+func NewMemoryOverflow() Overflow {
+	return &memoryOverflow{}
+}
+
+type overflowItem struct {
+	seq int64
+	v   interface{}
+}
+
+type memoryOverflow struct {
+	mu    sync.Mutex
+	items []overflowItem
+}
+
+func (o *memoryOverflow) Push(seq int64, v interface{}) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.items = append(o.items, overflowItem{seq: seq, v: v})
+	return nil
+}
+
+func (o *memoryOverflow) Pop() (int64, interface{}, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.items) == 0 {
+		return 0, nil, false, nil
+	}
+
+	item := o.items[0]
+	o.items[0] = overflowItem{}
+	o.items = o.items[1:]
+	return item.seq, item.v, true, nil
+}
+
+func (o *memoryOverflow) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.items)
+}
+
+// FileOverflow is an Overflow that keeps values in a file. Values are
+// encoded with a codec along with their type name, so their types must be
+// registered with the codec package. Markers can not be encoded and are
+// kept in memory.
+type FileOverflow struct {
+	mu    sync.Mutex
+	f     *os.File
+	typed *codec.Typed
+
+	roff, woff int64 // read and write offsets of the records
+	n          int
+	markers    []interface{}
+}
+
+const (
+	recordValue byte = iota
+	recordMarker
+)
+
+var errShortRecord = errors.New("overflow record is truncated")
+
+// NewFileOverflow returns a FileOverflow that uses the file at path. An
+// existing file is truncated.
+func NewFileOverflow(path string, c codec.Codec) (*FileOverflow, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileOverflow{
+		f:     f,
+		typed: codec.NewTyped(c),
+	}, nil
+}
+
+// Push appends a record of the value length, seq, kind and encoded value to
+// the file.
+func (o *FileOverflow) Push(seq int64, v interface{}) error {
+	kind, data := recordValue, []byte(nil)
+	if _, ok := v.(MarkerChan); ok {
+		kind = recordMarker
+	} else {
+		var err error
+		if data, err = o.typed.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	body := binary.AppendVarint(nil, seq)
+	body = append(body, kind)
+	body = append(body, data...)
+
+	rec := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(body)), uint64(len(body)))
+	rec = append(rec, body...)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := o.f.WriteAt(rec, o.woff); err != nil {
+		return err
+	}
+	o.woff += int64(len(rec))
+	o.n++
+	if kind == recordMarker {
+		o.markers = append(o.markers, v)
+	}
+	return nil
+}
+
+func (o *FileOverflow) Pop() (int64, interface{}, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.n == 0 {
+		return 0, nil, false, nil
+	}
+
+	body, err := o.next()
+	if err != nil {
+		return 0, nil, true, err
+	}
+
+	seq, sz := binary.Varint(body)
+	if sz <= 0 || sz == len(body) {
+		return 0, nil, true, errShortRecord
+	}
+
+	if body[sz] == recordMarker {
+		m := o.markers[0]
+		o.markers = o.markers[1:]
+		return seq, m, true, nil
+	}
+
+	v, err := o.typed.Decode(body[sz+1:])
+	return seq, v, true, err
+}
+
+func (o *FileOverflow) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.n
+}
+
+// Close closes and removes the file.
+func (o *FileOverflow) Close() error {
+	err := o.f.Close()
+	if rerr := os.Remove(o.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// next reads the next record body. The record is consumed even if it can
+// not be read, and the file is truncated once every record is consumed.
+// assumes mu held
+func (o *FileOverflow) next() ([]byte, error) {
+	defer func() {
+		o.n--
+		if o.n == 0 {
+			o.roff, o.woff = 0, 0
+			o.f.Truncate(0)
+		}
+	}()
+
+	var hdr [binary.MaxVarintLen64]byte
+	n, err := o.f.ReadAt(hdr[:], o.roff)
+	if err != nil && err != io.EOF {
+		o.roff = o.woff
+		return nil, err
+	}
+
+	size, sz := binary.Uvarint(hdr[:n])
+	if sz <= 0 || o.roff+int64(sz)+int64(size) > o.woff {
+		o.roff = o.woff
+		return nil, errShortRecord
+	}
+
+	body := make([]byte, size)
+	_, err = o.f.ReadAt(body, o.roff+int64(sz))
+	o.roff += int64(sz) + int64(size)
+	return body, err
+}
@@ -0,0 +1,208 @@
+package pubsub
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/benburkert/pubsub/codec"
+)
+
+func TestPauseSpill(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	ch := make(chan interface{}, 16)
+	o := NewMemoryOverflow()
+	sub, err := ps.SubChan(ch, WithPausePolicy(PauseSpill), WithOverflow(o))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub.Pause()
+	for i := 0; i < 10; i++ {
+		ps.Pub(i) // a blocked paused subscription would hang here
+	}
+	for sub.r.Load().Seq() < 10 {
+		runtime.Gosched()
+	}
+
+	if n := len(ch); n != 0 {
+		t.Errorf("want no values delivered while paused, got %d", n)
+	}
+	if n := sub.Lag(); n != 10 {
+		t.Errorf("want lag 10, got %d", n)
+	}
+
+	sub.Resume()
+	ps.Pub(10)
+
+	got := []interface{}{}
+	for len(got) < 11 {
+		got = append(got, <-ch)
+	}
+	if want := []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values %v, got %v", want, got)
+	}
+	if n := sub.Delivered(); n != 11 {
+		t.Errorf("want 11 delivered values, got %d", n)
+	}
+}
+
+func TestPauseSpillResumeIdle(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	ch := make(chan interface{}, 4)
+	o := NewMemoryOverflow()
+	sub, err := ps.SubChan(ch, WithPausePolicy(PauseSpill), WithOverflow(o))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub.Pause()
+	ps.PubSlice([]interface{}{"A", "B"})
+	for o.Len() < 2 {
+		runtime.Gosched()
+	}
+
+	// no value is published after resuming, the spilled values are
+	// delivered anyway.
+	sub.Resume()
+	if v := <-ch; v != "A" {
+		t.Errorf("want value A, got %v", v)
+	}
+	if v := <-ch; v != "B" {
+		t.Errorf("want value B, got %v", v)
+	}
+}
+
+func TestPauseDrop(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	ch := make(chan interface{}, 4)
+	sub, err := ps.SubChan(ch, WithPausePolicy(PauseDrop))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub.Pause()
+	for i := 0; i < 10; i++ {
+		ps.Pub(i)
+	}
+	for sub.Dropped() < 10 {
+		runtime.Gosched()
+	}
+
+	sub.Resume()
+	ps.Pub("A")
+	if v := <-ch; v != "A" {
+		t.Errorf("want value A, got %v", v)
+	}
+	if n := sub.Delivered(); n != 1 {
+		t.Errorf("want 1 delivered value, got %d", n)
+	}
+}
+
+func TestSpill(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := NewFileOverflow(filepath.Join(t.TempDir(), "overflow"), codec.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	gate := make(chan struct{})
+	got := []interface{}{}
+	sub, err := ps.SubFunc(func(v interface{}) {
+		if len(got) == 0 {
+			<-gate
+		}
+		got = append(got, v)
+	}, WithSpill(2), WithOverflow(o))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscription is stuck on the first value, a blocking
+	// subscription would hang the publisher after a few values.
+	for i := 0; i < 20; i++ {
+		ps.Pub(i)
+	}
+	for sub.Delivered() == 0 {
+		runtime.Gosched()
+	}
+
+	if n := sub.Lag(); n != 19 {
+		t.Errorf("want lag 19, got %d", n)
+	}
+	if n := o.Len(); n < 16 {
+		t.Errorf("want at least 16 spilled values, got %d", n)
+	}
+
+	close(gate)
+	ps.Close()
+
+	want := []interface{}{}
+	for i := 0; i < 20; i++ {
+		want = append(want, i)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want values %v, got %v", want, got)
+	}
+	if n := o.Len(); n != 0 {
+		t.Errorf("want drained overflow, got %d values", n)
+	}
+}
+
+func TestFileOverflow(t *testing.T) {
+	o, err := NewFileOverflow(filepath.Join(t.TempDir(), "overflow"), codec.Gob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	m := make(MarkerChan)
+	for i, v := range []interface{}{"A", m, int64(2)} {
+		if err := o.Push(int64(i), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := o.Push(3, struct{}{}); err == nil {
+		t.Error("want error for unregistered type")
+	}
+	if n := o.Len(); n != 3 {
+		t.Errorf("want 3 values, got %d", n)
+	}
+
+	for i, want := range []interface{}{"A", m, int64(2)} {
+		seq, v, ok, err := o.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || seq != int64(i) || v != want {
+			t.Errorf("want value %d %v, got %d %v %t", i, want, seq, v, ok)
+		}
+	}
+	if _, _, ok, _ := o.Pop(); ok {
+		t.Error("want empty overflow")
+	}
+	if fi, err := o.f.Stat(); err != nil || fi.Size() != 0 {
+		t.Errorf("want truncated file, got %v %v", fi.Size(), err)
+	}
+}
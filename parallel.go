@@ -0,0 +1,93 @@
+package pubsub
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+type parallelItem struct {
+	seq int64
+	v   interface{}
+}
+
+// SubFuncParallel subscribes fn to be called by a pool of workers. Values
+// with the same key, as returned by keyFn, are handled by the same worker in
+// publish order. A nil keyFn spreads values over the workers without any
+// ordering. The subscription only moves past a value in the buffer once fn
+// has returned for it and for every value before it, so slow handlers hold
+// back publishers just like a slow SubFunc.
+func (ps *PubSub) SubFuncParallel(fn func(interface{}), workers int, keyFn func(interface{}) string) (*Subscription, error) {
+	if workers < 1 {
+		return nil, errors.New("workers must be > 0")
+	}
+	if ps.isClosed() {
+		return nil, errClosed
+	}
+	s, err := ps.addSub()
+	if err != nil {
+		return nil, err
+	}
+	defer ps.startwg.Done()
+
+	// the reader is never more than a buffer length ahead of the slowest
+	// worker, so sends to a worker never block.
+	size := len(ps.buffer.data)
+	queues := make([]chan parallelItem, workers)
+	for i := range queues {
+		queues[i] = make(chan parallelItem, size)
+	}
+
+	acks := &ackSet{}
+	wg := &sync.WaitGroup{}
+	wg.Add(workers)
+	for _, q := range queues {
+		go func(q <-chan parallelItem) {
+			defer wg.Done()
+
+			for item := range q {
+				fn(item.v)
+				if acks.ack(item.seq) {
+					ps.buffer.wakeWriters()
+				}
+			}
+		}(q)
+	}
+	var endErr error // set before the queues are closed
+	go func() {
+		wg.Wait()
+		s.end(endErr)
+	}()
+
+	next := 0
+	rfn := func(seq int64, v interface{}) bool {
+		if vch, ok := v.(MarkerChan); ok {
+			if vch == ps.donec || vch == s.unsubc {
+				endErr = ps.endErr(vch)
+				for _, q := range queues {
+					close(q)
+				}
+				return false
+			}
+
+			acks.ack(seq)
+			return true
+		}
+
+		i := next
+		if keyFn != nil {
+			h := fnv.New32a()
+			h.Write([]byte(keyFn(v)))
+			i = int(h.Sum32() % uint32(workers))
+		} else {
+			next = (next + 1) % workers
+		}
+
+		queues[i] <- parallelItem{seq: seq, v: v}
+		return true
+	}
+
+	ps.track(s, ps.buffer.startReader(math.MaxInt64, acks, nil, rfn))
+	return s, nil
+}
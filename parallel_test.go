@@ -0,0 +1,135 @@
+package pubsub
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSubFuncParallelOrder(t *testing.T) {
+	ps, err := New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type item struct {
+		key string
+		n   int
+	}
+
+	mu := sync.Mutex{}
+	got := map[string][]int{}
+	fn := func(v interface{}) {
+		it := v.(item)
+
+		mu.Lock()
+		defer mu.Unlock()
+		got[it.key] = append(got[it.key], it.n)
+	}
+	keyFn := func(v interface{}) string { return v.(item).key }
+
+	if _, err := ps.SubFuncParallel(fn, 4, keyFn); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"a", "b", "c", "d", "e", "f"}
+	want := map[string][]int{}
+	for n := 0; n < 100; n++ {
+		for _, key := range keys {
+			ps.Pub(item{key, n})
+			want[key] = append(want[key], n)
+		}
+	}
+	ps.Close()
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want values by key %v, got %v", want, got)
+	}
+}
+
+func TestSubFuncParallelCursor(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slowc, fastc := make(chan struct{}), make(chan struct{}, 3)
+	fn := func(v interface{}) {
+		if v == "slow" {
+			<-slowc
+		} else {
+			fastc <- struct{}{}
+		}
+	}
+	keyFn := func(v interface{}) string {
+		if v == "slow" {
+			return "a"
+		}
+		return "b"
+	}
+
+	if _, err := ps.SubFuncParallel(fn, 2, keyFn); err != nil {
+		t.Fatal(err)
+	}
+	if keyFn("slow") == keyFn("fast") {
+		t.Fatal("want keys on different workers")
+	}
+
+	ps.PubSlice([]interface{}{"slow", "fast", "fast", "fast"})
+	for i := 0; i < 3; i++ {
+		<-fastc
+	}
+
+	c := ps.buffer.rcursors[0]
+	if seq := c.Seq(); seq != 0 {
+		t.Errorf("want cursor held at seq=0, got %d", seq)
+	}
+	if !lockedWriteBarrier(ps.buffer) {
+		t.Error("want writes to block on unfinished value")
+	}
+
+	close(slowc)
+	ps.Pub("fast")
+	<-fastc
+	ps.Close()
+
+	if seq := c.Seq(); seq != -1 {
+		t.Errorf("want cursor released, got seq=%d", seq)
+	}
+}
+
+func TestSubFuncParallelErrors(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ps.SubFuncParallel(func(interface{}) {}, 0, nil); err == nil {
+		t.Error("expected error for workers=0")
+	}
+
+	count := 0
+	if _, err := ps.SubFuncParallel(func(interface{}) { count++ }, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.SubFuncParallel(func(interface{}) {}, 1, nil); err != errMaxSub {
+		t.Errorf("unexpected error %q", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		ps.Pub(fmt.Sprint(i))
+	}
+	ps.Close()
+
+	if count != 10 {
+		t.Errorf("want count=10, got %d", count)
+	}
+}
+
+func lockedWriteBarrier(b *Buffer) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.writeBarrier()
+}
@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrStopped is the error of a ChanPublisher that was stopped before its
+// channel was closed.
+var ErrStopped = errors.New("publisher stopped")
+
+// ChanPublisher publishes the values of a channel. It is returned by
+// PubSub.PubChan.
+type ChanPublisher struct {
+	published, dropped atomic.Uint64
+
+	stopc chan struct{}
+	stopo sync.Once
+	donec chan struct{}
+	err   error
+}
+
+// Done returns a channel that is closed once the publisher stops reading
+// from its channel.
+func (p *ChanPublisher) Done() <-chan struct{} {
+	return p.donec
+}
+
+// Err returns why the publisher is done: nil once the channel was closed and
+// every value received from it was published, ErrStopped if Stop was
+// called, or an error if the PubSub was closed. It returns nil while the
+// publisher is running.
+func (p *ChanPublisher) Err() error {
+	select {
+	case <-p.donec:
+		return p.err
+	default:
+		return nil
+	}
+}
+
+// Stop stops reading from the channel and waits for the publisher to be
+// done. Values left in the channel are not published.
+func (p *ChanPublisher) Stop() {
+	p.stopo.Do(func() { close(p.stopc) })
+	<-p.donec
+}
+
+// Published returns the number of values published.
+func (p *ChanPublisher) Published() uint64 {
+	return p.published.Load()
+}
+
+// Dropped returns the number of values rejected by the publish rate limit or
+// dropped as duplicates.
+func (p *ChanPublisher) Dropped() uint64 {
+	return p.dropped.Load()
+}
+
+// drain discards the values of ch until it is closed, so senders to the
+// channel of a closed PubSub do not block.
+func drain(ch <-chan interface{}) {
+	for range ch {
+	}
+}
+
+func (p *ChanPublisher) publish(ps *PubSub, ch <-chan interface{}) {
+	defer close(p.donec)
+
+	for {
+		// prefer stopping over reading a value that is also ready.
+		select {
+		case <-p.stopc:
+			p.err = ErrStopped
+			return
+		case <-ps.donec:
+			p.err = errClosed
+			go drain(ch)
+			return
+		default:
+		}
+
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if dup, err := ps.publish(v, ps.buffer.ttl); dup || err != nil {
+				p.dropped.Add(1)
+			} else {
+				p.published.Add(1)
+			}
+		case <-p.stopc:
+			p.err = ErrStopped
+			return
+		case <-ps.donec:
+			p.err = errClosed
+			go drain(ch)
+			return
+		}
+	}
+}
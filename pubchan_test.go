@@ -0,0 +1,112 @@
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPubChanEndOfInput(t *testing.T) {
+	ps, err := New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 3)
+	ch <- "A"
+	ch <- nil
+	ch <- "B"
+	close(ch)
+
+	pub, err := ps.PubChan(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-pub.Done()
+
+	if err := pub.Err(); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	if n := pub.Published(); n != 3 {
+		t.Errorf("want 3 published values, got %d", n)
+	}
+	if want, got := []interface{}{"A", nil, "B"}, ps.buffer.Read(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want buffer %v, got %v", want, got)
+	}
+	ps.Close()
+}
+
+func TestPubChanStop(t *testing.T) {
+	ps, err := New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{})
+	pub, err := ps.PubChan(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Err(); err != nil {
+		t.Errorf("want nil error while running, got %v", err)
+	}
+
+	ch <- "A"
+	pub.Stop()
+	pub.Stop()
+
+	if err := pub.Err(); err != ErrStopped {
+		t.Errorf("want err %q, got %v", ErrStopped, err)
+	}
+	if n := pub.Published(); n != 1 {
+		t.Errorf("want 1 published value, got %d", n)
+	}
+
+	otherc := make(chan interface{})
+	other, err := ps.PubChan(otherc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps.Close()
+
+	// a closed PubSub keeps draining the channel.
+	otherc <- "B"
+	close(otherc)
+
+	select {
+	case <-other.Done():
+	default:
+		t.Fatal("want publisher done after close")
+	}
+	if err := other.Err(); err != errClosed {
+		t.Errorf("want err %q, got %v", errClosed, err)
+	}
+}
+
+func TestPubChanRateLimit(t *testing.T) {
+	ps, err := New(8, 1, WithClock(newFakeClock()), WithPubRateLimit(RateLimit{
+		Rate:   1,
+		Reject: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 2)
+	ch <- "A"
+	ch <- "B"
+	close(ch)
+
+	pub, err := ps.PubChan(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-pub.Done()
+
+	if n := pub.Published(); n != 1 {
+		t.Errorf("want 1 published value, got %d", n)
+	}
+	if n := pub.Dropped(); n != 1 {
+		t.Errorf("want 1 dropped value, got %d", n)
+	}
+	ps.Close()
+}
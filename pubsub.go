@@ -2,16 +2,23 @@ package pubsub
 
 import (
 	"errors"
+	"math"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/benburkert/pubsub/abool"
 )
 
 var (
-	errClosed = errors.New("PubSub is closed")
-	errMaxSub = errors.New("maxSubCount reached")
+	errClosed  = errors.New("PubSub is closed")
+	errMaxSub  = errors.New("maxSubCount reached")
+	errNameUse = errors.New("subscription name in use")
+	errNoName  = errors.New("no subscription with name")
 )
 
+const defaultCommitInterval = 100 * time.Millisecond
+
 type MarkerChan chan struct{}
 
 type PubSub struct {
@@ -24,11 +31,34 @@ type PubSub struct {
 	pubwg sync.WaitGroup
 	subwg sync.WaitGroup
 
+	// startwg counts subscriptions made by the subscribe methods that are
+	// registered but may not have started reading yet. closing is set once
+	// Close waits for them.
+	startwg sync.WaitGroup
+	closing bool
+
 	submu            sync.Mutex
 	subCount, subMax int
+	subID            uint64
+	subs             map[uint64]*Subscription
+	paused           bool // new subscriptions start paused
+
+	clock Clock
+	sched *scheduler
+	dedup *dedupSet
+
+	publimit  *RateLimit
+	stall     StallDetector
+	sublimits limiterSet
+
+	// named subscriptions, active or not, and their committed positions.
+	namemu         sync.Mutex
+	named          map[string]*namedSub
+	offsets        OffsetStore
+	commitInterval time.Duration
 }
 
-func New(minBufferSize, maxSubCount int) (*PubSub, error) {
+func New(minBufferSize, maxSubCount int, opts ...Option) (*PubSub, error) {
 	if minBufferSize < 2 {
 		return nil, errors.New("minBufferSize must be > 1")
 	}
@@ -37,12 +67,43 @@ func New(minBufferSize, maxSubCount int) (*PubSub, error) {
 		return nil, errors.New("maxSubCount must be > 0")
 	}
 
-	return &PubSub{
+	ps := &PubSub{
 		buffer: NewBuffer(minBufferSize, maxSubCount),
 		donec:  make(MarkerChan),
 		doneb:  abool.New(false),
 		subMax: maxSubCount,
-	}, nil
+		subs:   make(map[uint64]*Subscription),
+		clock:  realClock{},
+		named:  make(map[string]*namedSub),
+
+		commitInterval: defaultCommitInterval,
+	}
+
+	for _, opt := range opts {
+		opt(ps)
+	}
+
+	ps.buffer.clock = ps.clock
+	if ps.offsets == nil {
+		ps.offsets = NewMemoryOffsetStore()
+	}
+	if ps.dedup != nil {
+		ps.dedup.clock = ps.clock
+	}
+	if ps.publimit != nil {
+		l, err := newLimiter(*ps.publimit, ps.clock)
+		if err != nil {
+			return nil, err
+		}
+		ps.buffer.limit = l
+	}
+	if ps.stall.Hook != nil {
+		ps.buffer.stallAfter = ps.stall.Threshold
+		ps.buffer.onStall = ps.reportStall
+		ps.buffer.stallStacks = ps.stall.Stacks
+	}
+	ps.sched = newScheduler(ps.clock, func(v interface{}) { ps.write(v) })
+	return ps, nil
 }
 
 func (ps *PubSub) AddPublisher(pub Publisher) error {
@@ -59,24 +120,76 @@ func (ps *PubSub) AddPublisher(pub Publisher) error {
 	return pub.PublishTo(ctx)
 }
 
-func (ps *PubSub) AddSubscriber(sub Subscriber) error {
+// AddSubscriber subscribes sub. The returned Subscription reports the lag
+// and delivered count of the subscriber if it passes its Reader to
+// ctx.Track. Close does not wait for SubscribeTo to return: a subscriber
+// that tracks its Reader after Close is handed ctx.Done.
+func (ps *PubSub) AddSubscriber(sub Subscriber) (*Subscription, error) {
 	if ps.isClosed() {
-		return errClosed
+		return nil, errClosed
 	}
-	if !ps.addSub() {
-		return errMaxSub
+	s, err := ps.addSub()
+	if err != nil {
+		return nil, err
 	}
+	// Close does not wait for SubscribeTo, it ends the subscription and
+	// stops a reader tracked after the close marker instead.
+	ps.startwg.Done()
 
 	ctx := &Context{
 		Buffer: ps.buffer,
 		Done:   ps.donec,
-		Close:  ps.delSub,
+		Close: func() {
+			if ps.isClosed() {
+				s.end(errClosed)
+			} else {
+				s.end(nil)
+			}
+		},
+		Unsub: s.unsubc,
+		Track: func(r *Reader) {
+			ps.track(s, r)
+			if ps.isClosing() {
+				r.interrupt(ps.donec)
+			}
+		},
+	}
+	err = sub.SubscribeTo(ctx)
+	s.started()
+	if err != nil {
+		s.end(err)
+		return nil, err
 	}
-	return sub.SubscribeTo(ctx)
+	return s, nil
 }
 
 func (ps *PubSub) Close() {
 	ps.doneo.Do(func() {
+		ps.sched.close()
+
+		// a subscription that starts reading after the close marker
+		// would never stop, so wait for the ones being set up.
+		ps.submu.Lock()
+		ps.closing = true
+		ps.submu.Unlock()
+		ps.startwg.Wait()
+
+		// paused subscriptions would hold back the close marker, and
+		// subscribers still in SubscribeTo have no reader to read it.
+		var untracked []*Subscription
+		ps.submu.Lock()
+		ps.paused = false
+		for _, s := range ps.subs {
+			if s.r.Load() == nil {
+				untracked = append(untracked, s)
+			}
+			s.Resume()
+		}
+		ps.submu.Unlock()
+		for _, s := range untracked {
+			s.end(errClosed)
+		}
+
 		ps.buffer.Write(ps.donec)
 		ps.doneb.Set()
 		close(ps.donec)
@@ -91,33 +204,56 @@ func (ps *PubSub) Pub(v interface{}) error {
 		return errClosed
 	}
 
-	ps.buffer.Write(v)
-	return nil
+	return ps.write(v)
 }
 
-func (ps *PubSub) PubChan(ch <-chan interface{}) (<-chan struct{}, error) {
+// PubAt publishes v once the clock reaches t. The returned Scheduled can be
+// used to cancel the publish. Values still pending when the PubSub is closed
+// are discarded.
+func (ps *PubSub) PubAt(v interface{}, t time.Time) (*Scheduled, error) {
 	if ps.isClosed() {
 		return nil, errClosed
 	}
 
+	return ps.sched.add(v, t)
+}
+
+// PubAfter publishes v once duration d has elapsed.
+func (ps *PubSub) PubAfter(v interface{}, d time.Duration) (*Scheduled, error) {
+	return ps.PubAt(v, ps.clock.Now().Add(d))
+}
+
+// PubTTL publishes v with a time to live that overrides the default TTL.
+func (ps *PubSub) PubTTL(v interface{}, ttl time.Duration) error {
+	if ps.isClosed() {
+		return errClosed
+	}
+
+	return ps.writeTTL(v, ttl)
+}
+
+// PubChan publishes the values received from ch until ch is closed, the
+// returned ChanPublisher is stopped, or the PubSub is closed. Once the
+// PubSub is closed, the values received from ch are discarded until ch is
+// closed, so senders do not block.
+func (ps *PubSub) PubChan(ch <-chan interface{}) (*ChanPublisher, error) {
+	if ps.isClosed() {
+		return nil, errClosed
+	}
+
+	p := &ChanPublisher{
+		stopc: make(chan struct{}),
+		donec: make(chan struct{}),
+	}
+
 	ps.pubwg.Add(1)
 	go func() {
 		defer ps.pubwg.Done()
 
-		for {
-			select {
-			case v := <-ch:
-				ps.buffer.Write(v)
-			case <-ps.donec:
-				for v := range ch {
-					ps.buffer.Write(v)
-				}
-				return
-			}
-		}
+		p.publish(ps, ch)
 	}()
 
-	return ps.donec, nil
+	return p, nil
 }
 
 func (ps *PubSub) PubSlice(vs []interface{}) error {
@@ -125,29 +261,37 @@ func (ps *PubSub) PubSlice(vs []interface{}) error {
 		return errClosed
 	}
 
-	ps.buffer.WriteSlice(vs)
+	if ps.dedup != nil {
+		vs = ps.dedup.filter(vs)
+	}
+
+	if err := ps.buffer.admit(vs...); err != nil {
+		if ps.dedup != nil {
+			ps.dedup.forget(vs...)
+		}
+		return err
+	}
+	ps.buffer.put(ps.buffer.ttl, vs...)
 	return nil
 }
 
-func (ps *PubSub) SubChan(ch chan<- interface{}) (chan<- struct{}, error) {
+// SubChan sends every published value to ch. ch is closed when the
+// subscription stops.
+func (ps *PubSub) SubChan(ch chan<- interface{}, opts ...SubOption) (*Subscription, error) {
 	if ps.isClosed() {
 		return nil, errClosed
 	}
-	if !ps.addSub() {
-		return nil, errMaxSub
+	s, err := ps.addSub()
+	if err != nil {
+		return nil, err
 	}
-
-	unsubc := make(MarkerChan)
-	go func() {
-		<-unsubc
-		ps.Pub(unsubc)
-	}()
+	defer ps.startwg.Done()
 
 	rfn := func(v interface{}) bool {
 		if vch, ok := v.(MarkerChan); ok {
-			if vch == ps.donec || vch == unsubc {
+			if vch == ps.donec || vch == s.unsubc {
 				close(ch)
-				ps.delSub()
+				s.end(ps.endErr(vch))
 				return false
 			}
 		} else {
@@ -156,27 +300,24 @@ func (ps *PubSub) SubChan(ch chan<- interface{}) (chan<- struct{}, error) {
 		return true
 	}
 
-	ps.buffer.ReadTo(rfn)
-	return unsubc, nil
+	return ps.subscribe(s, rfn, opts)
 }
 
-func (ps *PubSub) SubFunc(fn func(interface{})) (func(), error) {
+// SubFunc calls fn with every published value.
+func (ps *PubSub) SubFunc(fn func(interface{}), opts ...SubOption) (*Subscription, error) {
 	if ps.isClosed() {
 		return nil, errClosed
 	}
-	if !ps.addSub() {
-		return nil, errMaxSub
-	}
-
-	unsubc := make(MarkerChan)
-	unsubfn := func() {
-		ps.Pub(unsubc)
+	s, err := ps.addSub()
+	if err != nil {
+		return nil, err
 	}
+	defer ps.startwg.Done()
 
 	rfn := func(v interface{}) bool {
 		if vch, ok := v.(MarkerChan); ok {
-			if vch == ps.donec || vch == unsubc {
-				ps.delSub()
+			if vch == ps.donec || vch == s.unsubc {
+				s.end(ps.endErr(vch))
 				return false
 			}
 		} else {
@@ -185,31 +326,301 @@ func (ps *PubSub) SubFunc(fn func(interface{})) (func(), error) {
 		return true
 	}
 
-	ps.buffer.ReadTo(rfn)
-	return unsubfn, nil
+	return ps.subscribe(s, rfn, opts)
+}
+
+// Commit stores the position of the named subscription in the OffsetStore.
+// Every value handed to the subscription is committed, including the value
+// whose handler is still running, so a handler can commit the value it has
+// just processed. It is only needed by subscriptions made with
+// WithManualCommit, others commit the values they handled every commit
+// interval and when they end.
+func (ps *PubSub) Commit(name string) error {
+	ps.namemu.Lock()
+	ns, ok := ps.named[name]
+	ps.namemu.Unlock()
+
+	if !ok || ns.r == nil {
+		return errNoName
+	}
+	return ns.commit(ps.offsets)
+}
+
+// Stats returns counters describing the PubSub.
+func (ps *PubSub) Stats() Stats {
+	s := Stats{
+		Expired: ps.buffer.Expired(),
+	}
+	if ps.dedup != nil {
+		s.Duplicates = ps.dedup.dropCount()
+	}
+	if ps.buffer.limit != nil {
+		ls := ps.buffer.limit.stats()
+		s.PubLimiter = &ls
+	}
+	s.SubLimiters = ps.sublimits.stats()
+	s.CommitErrors = ps.commitErrors()
+	return s
+}
+
+// Subscriptions returns the active subscriptions ordered by ID.
+func (ps *PubSub) Subscriptions() []*Subscription {
+	ps.submu.Lock()
+	subs := make([]*Subscription, 0, len(ps.subs))
+	for _, s := range ps.subs {
+		subs = append(subs, s)
+	}
+	ps.submu.Unlock()
+
+	sort.Slice(subs, func(i, j int) bool { return subs[i].id < subs[j].id })
+	return subs
+}
+
+// Pause pauses every subscription, including the ones made until Resume is
+// called. See Subscription.Pause.
+func (ps *PubSub) Pause() {
+	ps.setPaused(true)
+}
+
+// Resume resumes every subscription after Pause, including the ones paused
+// on their own.
+func (ps *PubSub) Resume() {
+	ps.setPaused(false)
+}
+
+// Paused reports whether the PubSub is paused.
+func (ps *PubSub) Paused() bool {
+	ps.submu.Lock()
+	defer ps.submu.Unlock()
+
+	return ps.paused
+}
+
+func (ps *PubSub) setPaused(paused bool) {
+	ps.submu.Lock()
+	defer ps.submu.Unlock()
+
+	if ps.closing {
+		return
+	}
+	ps.paused = paused
+	for _, s := range ps.subs {
+		if paused {
+			s.Pause()
+		} else {
+			s.Resume()
+		}
+	}
+}
+
+// Peek returns up to the last n published values still held by the
+// buffer, oldest first.
+func (ps *PubSub) Peek(n int) []interface{} {
+	ps.buffer.mu.RLock()
+	vs := ps.buffer.readBefore(ps.buffer.wcursor.Seq())
+	ps.buffer.mu.RUnlock()
+
+	peek := make([]interface{}, 0, n)
+	for i := len(vs) - 1; i >= 0 && len(peek) < n; i-- {
+		if _, ok := vs[i].(MarkerChan); !ok {
+			peek = append(peek, vs[i])
+		}
+	}
+	for i, j := 0, len(peek)-1; i < j; i, j = i+1, j-1 {
+		peek[i], peek[j] = peek[j], peek[i]
+	}
+	return peek
+}
+
+// subscribe starts the reader of s.
+func (ps *PubSub) subscribe(s *Subscription, rfn ReaderFunc, opts []SubOption) (*Subscription, error) {
+	cfg := newSubConfig(opts)
+	r, err := ps.readTo(rfn, cfg)
+	if err != nil {
+		s.started()
+		s.end(err)
+		return nil, err
+	}
+
+	r.SetPausePolicy(cfg.pausePolicy, cfg.overflow)
+	ps.track(s, r)
+	return s, nil
+}
+
+// track passes r to s, paused if the PubSub is paused.
+func (ps *PubSub) track(s *Subscription, r *Reader) {
+	ps.submu.Lock()
+	defer ps.submu.Unlock()
+
+	if ps.paused {
+		r.Pause()
+	}
+	s.track(r)
+}
+
+// readTo starts a reader for a subscription, paced by its rate limit.
+func (ps *PubSub) readTo(rfn ReaderFunc, cfg *subConfig) (*Reader, error) {
+	if cfg.limit == nil {
+		return ps.startReader(rfn, cfg)
+	}
+
+	l, err := newLimiter(*cfg.limit, ps.clock)
+	if err != nil {
+		return nil, err
+	}
+	ps.sublimits.add(l, cfg.name)
+
+	paced := l.pace(rfn)
+	rfn = func(v interface{}) bool {
+		if !paced(v) {
+			ps.sublimits.remove(l)
+			return false
+		}
+		return true
+	}
+
+	r, err := ps.startReader(rfn, cfg)
+	if err != nil {
+		ps.sublimits.remove(l)
+		return nil, err
+	}
+	return r, nil
+}
+
+// startReader starts the reader of a subscription. Named subscriptions
+// continue from their committed position.
+func (ps *PubSub) startReader(rfn ReaderFunc, cfg *subConfig) (*Reader, error) {
+	var spill *spillConfig
+	if cfg.spill {
+		spill = ps.buffer.newSpillConfig(cfg.spillThreshold, cfg.overflow)
+	}
+
+	if cfg.name == "" {
+		return ps.buffer.startReader(math.MaxInt64, nil, spill, rfn.withSeq()), nil
+	}
+
+	ps.namemu.Lock()
+	defer ps.namemu.Unlock()
+
+	if ns, ok := ps.named[cfg.name]; ok && ns.active() {
+		return nil, errNameUse
+	}
+
+	seq, ok, err := ps.offsets.Load(cfg.name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		seq = math.MaxInt64 // clamped to the write sequence
+	}
+
+	ns := newNamedSub(cfg)
+	ns.r = ps.buffer.startReader(seq, nil, spill, func(seq int64, v interface{}) bool {
+		// a marker handed over by an interrupt is not at seq.
+		if _, ok := v.(MarkerChan); !ok {
+			ns.delivered.Store(seq + 1)
+		}
+		if !rfn(v) {
+			if !ns.manual {
+				ns.autoCommit(ps.offsets)
+			}
+			return false
+		}
+		if _, ok := v.(MarkerChan); !ok && !ns.manual {
+			ns.handled.Store(seq + 1)
+			ns.scheduleCommit(ps.clock, ps.commitInterval, ps.offsets)
+		}
+		return true
+	})
+	ps.named[cfg.name] = ns
+	return ns.r, nil
 }
 
-func (ps *PubSub) addSub() bool {
+// isClosing reports whether Close has started.
+func (ps *PubSub) isClosing() bool {
 	ps.submu.Lock()
 	defer ps.submu.Unlock()
 
+	return ps.closing
+}
+
+// addSub registers a new Subscription. The caller must call
+// ps.startwg.Done once the subscription is reading.
+func (ps *PubSub) addSub() (*Subscription, error) {
+	ps.submu.Lock()
+	defer ps.submu.Unlock()
+
+	if ps.closing {
+		return nil, errClosed
+	}
 	if ps.subCount == ps.subMax {
-		return false
+		return nil, errMaxSub
 	}
 
 	ps.subCount++
 	ps.subwg.Add(1)
-	return true
+	ps.startwg.Add(1)
+
+	ps.subID++
+	s := &Subscription{
+		id:     ps.subID,
+		ps:     ps,
+		unsubc: make(MarkerChan),
+		startc: make(chan struct{}),
+		donec:  make(chan struct{}),
+	}
+	ps.subs[s.id] = s
+	return s, nil
 }
 
-func (ps *PubSub) delSub() {
+func (ps *PubSub) delSub(s *Subscription) {
 	ps.submu.Lock()
 	defer ps.submu.Unlock()
 
+	delete(ps.subs, s.id)
 	ps.subCount--
 	ps.subwg.Done()
 }
 
+// endErr returns the error of a subscription stopped by marker m.
+func (ps *PubSub) endErr(m MarkerChan) error {
+	if m == ps.donec {
+		return errClosed
+	}
+	return nil
+}
+
+func (ps *PubSub) write(v interface{}) error {
+	return ps.writeTTL(v, ps.buffer.ttl)
+}
+
+func (ps *PubSub) writeTTL(v interface{}, ttl time.Duration) error {
+	_, err := ps.publish(v, ttl)
+	return err
+}
+
+// publish writes v to the buffer unless it is a duplicate, and reports
+// whether it was dropped as one. The ID of a value rejected by the rate
+// limit is forgotten, so a retry is not treated as a duplicate.
+func (ps *PubSub) publish(v interface{}, ttl time.Duration) (dup bool, err error) {
+	if ps.isDup(v) {
+		return true, nil
+	}
+	if err := ps.buffer.admit(v); err != nil {
+		if ps.dedup != nil {
+			ps.dedup.forget(v)
+		}
+		return false, err
+	}
+
+	ps.buffer.put(ttl, v)
+	return false, nil
+}
+
+func (ps *PubSub) isDup(v interface{}) bool {
+	return ps.dedup != nil && ps.dedup.seen(v)
+}
+
 func (ps *PubSub) isClosed() bool {
 	return ps.doneb.Test()
 }
@@ -2,9 +2,11 @@ package pubsub
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestPubSubErrors(t *testing.T) {
@@ -132,13 +134,14 @@ func TestPubSubFuncUnsubscribe(t *testing.T) {
 	}
 	defer ps.Close()
 
-	var unsubfn func()
+	var sub *Subscription
 	count := 0
 	stepper := make(chan struct{})
 	subfn := func(interface{}) {
 		if count == 5 {
 			go func() {
-				unsubfn()
+				sub.Unsubscribe()
+				<-sub.Done()
 				close(stepper)
 			}()
 
@@ -152,7 +155,7 @@ func TestPubSubFuncUnsubscribe(t *testing.T) {
 		count++
 	}
 
-	if unsubfn, err = ps.SubFunc(subfn); err != nil {
+	if sub, err = ps.SubFunc(subfn); err != nil {
 		t.Fatal(err)
 	}
 
@@ -171,7 +174,7 @@ func TestPubSubChanUnsubscribe(t *testing.T) {
 	defer ps.Close()
 
 	ch := make(chan interface{})
-	unsubch, err := ps.SubChan(ch)
+	sub, err := ps.SubChan(ch)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -181,7 +184,7 @@ func TestPubSubChanUnsubscribe(t *testing.T) {
 		count := 0
 		for range ch {
 			if count == 5 {
-				close(unsubch)
+				sub.Unsubscribe()
 			}
 			count++
 		}
@@ -193,3 +196,133 @@ func TestPubSubChanUnsubscribe(t *testing.T) {
 	}
 	<-donec
 }
+
+func TestPubSubDeadLetter(t *testing.T) {
+	dl, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlch := make(chan interface{}, 4)
+	if _, err := dl.SubChan(dlch); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := newFakeClock()
+	ps, err := New(4, 1, WithClock(clock), WithTTL(time.Second), WithDeadLetter(dl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []interface{}{}
+	readc := make(chan struct{})
+	fn := func(v interface{}) {
+		if v == "A" {
+			clock.Advance(time.Second)
+			defer close(readc)
+		}
+		got = append(got, v)
+	}
+	if _, err := ps.SubFunc(fn); err != nil {
+		t.Fatal(err)
+	}
+
+	ps.PubSlice([]interface{}{"A", "B", "C"})
+	// the ring has room for D, wait for the clock to move past B and C.
+	<-readc
+	ps.Pub("D")
+	ps.Close()
+
+	if want := []interface{}{"A", "D"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values %v, got %v", want, got)
+	}
+	if n := ps.Stats().Expired; n != 2 {
+		t.Errorf("want expired count=2, got %d", n)
+	}
+
+	dl.Close()
+	for _, want := range []string{"B", "C"} {
+		if v := <-dlch; v != want {
+			t.Errorf("want dead letter %q, got %q", want, v)
+		}
+	}
+}
+
+func TestPubSubSpillExpiry(t *testing.T) {
+	clock := newFakeClock()
+
+	var ps *PubSub
+	expired := []interface{}{}
+	ps, err := New(4, 1, WithClock(clock), WithTTL(time.Second), WithExpiry(func(v interface{}) {
+		expired = append(expired, v)
+		ps.Pub(fmt.Sprintf("expired %v", v))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gate := make(chan struct{})
+	readc := make(chan struct{})
+	got := []interface{}{}
+	if _, err := ps.SubFunc(func(v interface{}) {
+		if len(got) == 0 {
+			close(readc)
+			<-gate
+		}
+		got = append(got, v)
+	}, WithSpill(2), WithOverflow(NewMemoryOverflow())); err != nil {
+		t.Fatal(err)
+	}
+
+	ps.Pub("A")
+	<-readc
+	ps.PubSlice([]interface{}{"B", "C"})
+	clock.Advance(time.Second)
+	ps.Pub("D")
+	// spills B, C and D, the callback publishes the expired B and C from
+	// this goroutine.
+	ps.Pub("E")
+
+	close(gate)
+	ps.Close()
+
+	if want := []interface{}{"B", "C"}; !reflect.DeepEqual(want, expired) {
+		t.Errorf("want expired values %v, got %v", want, expired)
+	}
+	if want := []interface{}{"A", "D", "E", "expired B", "expired C"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values %v, got %v", want, got)
+	}
+}
+
+func TestPubSubSubscriptionsPeek(t *testing.T) {
+	ps, err := New(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := ps.SubFunc(func(interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ps.SubFunc(func(interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subs := ps.Subscriptions(); !reflect.DeepEqual(subs, []*Subscription{a, b}) {
+		t.Errorf("want subscriptions %v, got %v", []*Subscription{a, b}, subs)
+	}
+
+	ps.PubSlice([]interface{}{"A", "B", "C"})
+	if want, got := []interface{}{"B", "C"}, ps.Peek(2); !reflect.DeepEqual(want, got) {
+		t.Errorf("want peek %v, got %v", want, got)
+	}
+
+	b.Unsubscribe()
+	<-b.Done()
+	if want, got := []interface{}{"A", "B", "C"}, ps.Peek(10); !reflect.DeepEqual(want, got) {
+		t.Errorf("want peek %v, got %v", want, got)
+	}
+	if subs := ps.Subscriptions(); !reflect.DeepEqual(subs, []*Subscription{a}) {
+		t.Errorf("want subscriptions %v, got %v", []*Subscription{a}, subs)
+	}
+	ps.Close()
+}
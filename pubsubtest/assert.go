@@ -0,0 +1,51 @@
+package pubsubtest
+
+import (
+	"reflect"
+	"testing"
+)
+
+// AssertValues fails t unless got and want hold equal values in the same
+// order.
+func AssertValues(t testing.TB, got, want []interface{}) {
+	t.Helper()
+
+	for i := 0; i < len(got) && i < len(want); i++ {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("want value %#v at index %d, got %#v", want[i], i, got[i])
+			return
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("want %d values %v, got %d values %v", len(want), want, len(got), got)
+	}
+}
+
+// AssertSubsequence fails t unless the values of want appear in got in
+// order, such as the values delivered to a subscriber that drops values.
+func AssertSubsequence(t testing.TB, got, want []interface{}) {
+	t.Helper()
+
+	i := 0
+	for _, v := range got {
+		if i < len(want) && reflect.DeepEqual(v, want[i]) {
+			i++
+		}
+	}
+	if i < len(want) {
+		t.Errorf("want subsequence %v, got %v: missing %#v", want, got, want[i])
+	}
+}
+
+// AssertIncreasing fails t unless seqs are strictly increasing, so no value
+// was delivered twice or out of order.
+func AssertIncreasing(t testing.TB, seqs []int64) {
+	t.Helper()
+
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Errorf("want increasing sequence numbers, got %d after %d at index %d", seqs[i], seqs[i-1], i)
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package pubsubtest
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recordingTB records the failures of assertions.
+type recordingTB struct {
+	testing.TB
+	errs []string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Errorf(format string, args ...interface{}) {
+	tb.errs = append(tb.errs, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		name   string
+		assert func(testing.TB)
+		fail   bool
+	}{
+		{"values", func(tb testing.TB) { AssertValues(tb, []interface{}{"A", 1}, []interface{}{"A", 1}) }, false},
+		{"values mismatch", func(tb testing.TB) { AssertValues(tb, []interface{}{"A", 2}, []interface{}{"A", 1}) }, true},
+		{"values length", func(tb testing.TB) { AssertValues(tb, []interface{}{"A"}, []interface{}{"A", 1}) }, true},
+		{"subsequence", func(tb testing.TB) { AssertSubsequence(tb, []interface{}{"A", "B", "C"}, []interface{}{"A", "C"}) }, false},
+		{"subsequence order", func(tb testing.TB) { AssertSubsequence(tb, []interface{}{"C", "A"}, []interface{}{"A", "C"}) }, true},
+		{"increasing", func(tb testing.TB) { AssertIncreasing(tb, []int64{1, 2, 4}) }, false},
+		{"increasing duplicate", func(tb testing.TB) { AssertIncreasing(tb, []int64{1, 2, 2}) }, true},
+	}
+
+	for _, test := range tests {
+		tb := &recordingTB{TB: t}
+		test.assert(tb)
+		if failed := len(tb.errs) > 0; failed != test.fail {
+			t.Errorf("%s: want failure %t, got %q", test.name, test.fail, tb.errs)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package pubsubtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benburkert/pubsub"
+)
+
+// FakeClock is a pubsub.Clock that only moves when it is advanced. Pass it
+// to pubsub.WithClock to make scheduled publishing, TTLs and rate limits
+// deterministic.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set to start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// AfterFunc calls fn once the clock is advanced past d from now.
+func (c *FakeClock) AfterFunc(d time.Duration, fn func()) pubsub.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{c: c, at: c.now.Add(d), fn: fn}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, synchronously running every timer
+// that comes due in order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].at.Before(c.timers[j].at)
+		})
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		c.mu.Unlock()
+
+		t.fn()
+	}
+}
+
+// Pending returns the number of timers that have not fired or been
+// stopped.
+func (c *FakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// AwaitPending waits for at least n pending timers, such as the timer of
+// a rate limited publisher that is about to sleep, and reports whether
+// they were created within timeout.
+func (c *FakeClock) AwaitPending(n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for c.Pending() < n {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+type fakeTimer struct {
+	c  *FakeClock
+	at time.Time
+	fn func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	for i, ft := range t.c.timers {
+		if ft == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package pubsubtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benburkert/pubsub"
+)
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ps, err := pubsub.New(4, 1, pubsub.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	r := Subscribe(t, ps)
+	if _, err := ps.PubAfter("B", 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.PubAfter("A", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if clock.Pending() == 0 {
+		t.Error("want a pending timer")
+	}
+
+	clock.Advance(time.Second)
+	if vs, err := r.AwaitN(1, time.Second); err != nil {
+		t.Fatal(err)
+	} else {
+		AssertValues(t, vs, []interface{}{"A"})
+	}
+
+	clock.Advance(time.Second)
+	if vs, err := r.AwaitN(2, time.Second); err != nil {
+		t.Fatal(err)
+	} else {
+		AssertValues(t, vs, []interface{}{"A", "B"})
+	}
+
+	if want, got := time.Unix(2, 0), clock.Now(); !want.Equal(got) {
+		t.Errorf("want time %v, got %v", want, got)
+	}
+}
+
+func TestFakeClockAwaitPending(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ps, err := pubsub.New(4, 1, pubsub.WithClock(clock), pubsub.WithPubRateLimit(pubsub.RateLimit{Rate: 1, Burst: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	r := Subscribe(t, ps)
+	ps.Pub("A")
+	go ps.Pub("B") // waits for a token
+
+	if !clock.AwaitPending(1, time.Second) {
+		t.Fatal("want rate limited publisher to wait")
+	}
+	clock.Advance(time.Second)
+
+	if _, err := r.AwaitN(2, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,76 @@
+package pubsubtest
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// LeakTimeout is how long CheckLeaks waits for goroutines to exit.
+var LeakTimeout = time.Second
+
+// CheckLeaks fails t if goroutines running pubsub code, that were started
+// after CheckLeaks was called, are still running when the test ends. Close
+// the PubSubs of the test before it returns, for example with a defer.
+func CheckLeaks(t testing.TB) {
+	before := goroutines()
+
+	t.Cleanup(func() {
+		t.Helper()
+
+		var leaked []string
+		deadline := time.Now().Add(LeakTimeout)
+		for {
+			leaked = leaked[:0]
+			for id, stack := range goroutines() {
+				if _, ok := before[id]; !ok && isPubSub(stack) {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		for _, stack := range leaked {
+			t.Errorf("pubsubtest: leaked goroutine:\n%s", stack)
+		}
+	})
+}
+
+// goroutines returns the stacks of the running goroutines by their
+// "goroutine N" header.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	gs := make(map[string]string)
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		stack := string(g)
+		id, _, _ := strings.Cut(stack, " [")
+		gs[id] = stack
+	}
+	return gs
+}
+
+// isPubSub reports whether a goroutine runs code of the pubsub module,
+// other than this package.
+func isPubSub(stack string) bool {
+	for _, line := range strings.Split(stack, "\n") {
+		if strings.HasPrefix(line, "github.com/benburkert/pubsub") &&
+			!strings.HasPrefix(line, "github.com/benburkert/pubsub/pubsubtest.") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package pubsubtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benburkert/pubsub"
+)
+
+// cleanupTB runs cleanups when the fake test ends.
+type cleanupTB struct {
+	recordingTB
+	cleanups []func()
+}
+
+func (tb *cleanupTB) Cleanup(fn func()) {
+	tb.cleanups = append(tb.cleanups, fn)
+}
+
+func (tb *cleanupTB) end() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+func TestCheckLeaks(t *testing.T) {
+	defer func(d time.Duration) { LeakTimeout = d }(LeakTimeout)
+	LeakTimeout = 20 * time.Millisecond
+
+	tb := &cleanupTB{recordingTB: recordingTB{TB: t}}
+	CheckLeaks(tb)
+
+	ps, err := pubsub.New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Subscribe(t, ps)
+
+	// the subscription of an open PubSub is a leak.
+	tb.end()
+	if len(tb.errs) == 0 {
+		t.Error("want leaked subscriber reported")
+	}
+
+	tb = &cleanupTB{recordingTB: recordingTB{TB: t}}
+	CheckLeaks(tb)
+
+	ps.Close()
+	<-r.Done()
+	tb.end()
+	if len(tb.errs) != 0 {
+		t.Errorf("want no leaks after close, got %q", tb.errs)
+	}
+}
@@ -0,0 +1,212 @@
+// Package pubsubtest provides utilities for testing code that uses a
+// PubSub without sleeping: a Recorder subscriber to wait for and inspect
+// delivered values, assertion helpers, a FakeClock for scheduled and
+// expiring values, faults for slow or failing subscribers, and a goroutine
+// leak check.
+package pubsubtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benburkert/pubsub"
+)
+
+// ErrTimeout is returned by AwaitN when too few values arrive in time.
+var ErrTimeout = errors.New("pubsubtest: timed out waiting for values")
+
+// ErrStopped is returned by AwaitN when the subscription stops before
+// enough values arrive.
+var ErrStopped = errors.New("pubsubtest: subscription stopped")
+
+// PanicError is the error of a Recorder that stopped because of a PanicOn
+// fault.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("pubsubtest: subscriber panicked on %v", e.Value)
+}
+
+// Fault makes a Recorder misbehave like a faulty subscriber.
+type Fault func(*Recorder)
+
+// Delay makes the Recorder sleep for d before it records each value, like
+// a slow subscriber that holds back publishers.
+func Delay(d time.Duration) Fault {
+	return func(r *Recorder) {
+		r.delay = d
+	}
+}
+
+// PanicOn makes the handler of the Recorder panic on values for which fn
+// returns true. The Recorder recovers the panic and stops, like a
+// supervised subscriber that crashed, and Err returns a *PanicError.
+func PanicOn(fn func(v interface{}) bool) Fault {
+	return func(r *Recorder) {
+		r.panicOn = fn
+	}
+}
+
+// StopAfter makes the Recorder stop reading after n values, like a
+// subscriber that quits without unsubscribing.
+func StopAfter(n int) Fault {
+	return func(r *Recorder) {
+		r.stopAfter = n
+	}
+}
+
+// Recorder is a Subscriber that records every value delivered to it along
+// with its sequence number.
+type Recorder struct {
+	delay     time.Duration
+	panicOn   func(interface{}) bool
+	stopAfter int
+
+	mu      sync.Mutex
+	values  []interface{}
+	seqs    []int64
+	changec chan struct{} // closed and replaced after each value
+
+	donec chan struct{}
+	err   error
+}
+
+// NewRecorder returns a Recorder with faults.
+func NewRecorder(faults ...Fault) *Recorder {
+	r := &Recorder{
+		changec: make(chan struct{}),
+		donec:   make(chan struct{}),
+	}
+	for _, f := range faults {
+		f(r)
+	}
+	return r
+}
+
+// Subscribe subscribes a new Recorder to ps, failing t if it can not.
+func Subscribe(t testing.TB, ps *pubsub.PubSub, faults ...Fault) *Recorder {
+	t.Helper()
+
+	r := NewRecorder(faults...)
+	if _, err := ps.AddSubscriber(r); err != nil {
+		t.Fatalf("pubsubtest: subscribe: %v", err)
+	}
+	return r
+}
+
+// SubscribeTo starts recording the values published after it is called.
+// It stops when it is unsubscribed, when the PubSub is closed, or on a
+// fault.
+func (r *Recorder) SubscribeTo(ctx *pubsub.Context) error {
+	rfn := func(seq int64, v interface{}) bool {
+		if _, ok := v.(pubsub.MarkerChan); ok {
+			if v != ctx.Done && v != ctx.Unsub {
+				return true
+			}
+		} else if r.handle(seq, v) {
+			return true
+		}
+
+		close(r.donec)
+		ctx.Close()
+		return false
+	}
+
+	ctx.Track(ctx.Buffer.ReadFromSeq(math.MaxInt64, rfn))
+	return nil
+}
+
+// handle records v, and reports whether the Recorder continues.
+func (r *Recorder) handle(seq int64, v interface{}) (ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			r.err = &PanicError{Value: p}
+			ok = false
+		}
+	}()
+
+	if r.delay > 0 {
+		time.Sleep(r.delay)
+	}
+	if r.panicOn != nil && r.panicOn(v) {
+		panic(v)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.values = append(r.values, v)
+	r.seqs = append(r.seqs, seq)
+	close(r.changec)
+	r.changec = make(chan struct{})
+
+	return r.stopAfter == 0 || len(r.values) < r.stopAfter
+}
+
+// AwaitN waits for at least n values to be recorded and returns the first
+// n. It returns the values recorded so far and ErrTimeout if they do not
+// arrive within timeout, or ErrStopped if the Recorder stops first.
+func (r *Recorder) AwaitN(n int, timeout time.Duration) ([]interface{}, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		r.mu.Lock()
+		vs, changec := r.values, r.changec
+		r.mu.Unlock()
+
+		if len(vs) >= n {
+			return append([]interface{}(nil), vs[:n]...), nil
+		}
+
+		select {
+		case <-changec:
+		case <-r.donec:
+			return r.Values(), ErrStopped
+		case <-timer.C:
+			return r.Values(), ErrTimeout
+		}
+	}
+}
+
+// Values returns the values recorded so far.
+func (r *Recorder) Values() []interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]interface{}(nil), r.values...)
+}
+
+// Seqs returns the sequence numbers of the values recorded so far.
+func (r *Recorder) Seqs() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]int64(nil), r.seqs...)
+}
+
+// Len returns the number of values recorded so far.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.values)
+}
+
+// Done is closed once the Recorder stops recording.
+func (r *Recorder) Done() <-chan struct{} {
+	return r.donec
+}
+
+// Err waits for the Recorder to stop and returns the fault that stopped
+// it, or nil.
+func (r *Recorder) Err() error {
+	<-r.donec
+	return r.err
+}
@@ -0,0 +1,71 @@
+package pubsubtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benburkert/pubsub"
+)
+
+func TestRecorder(t *testing.T) {
+	CheckLeaks(t)
+
+	ps, err := pubsub.New(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	r := Subscribe(t, ps)
+	for _, v := range []string{"A", "B", "C"} {
+		ps.Pub(v)
+	}
+
+	vs, err := r.AwaitN(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertValues(t, vs, []interface{}{"A", "B"})
+
+	if _, err := r.AwaitN(3, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	AssertIncreasing(t, r.Seqs())
+
+	if _, err := r.AwaitN(4, 10*time.Millisecond); err != ErrTimeout {
+		t.Errorf("want error %q, got %v", ErrTimeout, err)
+	}
+}
+
+func TestRecorderFaults(t *testing.T) {
+	CheckLeaks(t)
+
+	ps, err := pubsub.New(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	panicky := Subscribe(t, ps, PanicOn(func(v interface{}) bool { return v == "B" }))
+	quitter := Subscribe(t, ps, StopAfter(1))
+	slow := Subscribe(t, ps, Delay(time.Millisecond))
+
+	for _, v := range []string{"A", "B", "C"} {
+		ps.Pub(v)
+	}
+
+	if err, ok := panicky.Err().(*PanicError); !ok || err.Value != "B" {
+		t.Errorf("want panic on %q, got %v", "B", panicky.Err())
+	}
+	AssertValues(t, panicky.Values(), []interface{}{"A"})
+
+	if vs, err := quitter.AwaitN(2, time.Second); err != ErrStopped {
+		t.Errorf("want error %q, got %v", ErrStopped, err)
+	} else {
+		AssertValues(t, vs, []interface{}{"A"})
+	}
+
+	if _, err := slow.AwaitN(3, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,178 @@
+package pubsub
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRateLimited is returned when a value is published over a rate limit
+// that rejects instead of blocking.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimit configures a token bucket. Every value takes one token and the
+// bucket refills at Rate tokens per second up to Burst tokens.
+type RateLimit struct {
+	// Rate is the number of tokens added per second. It must be positive.
+	Rate float64
+
+	// Burst is the capacity of the bucket. It is at least 1.
+	Burst int
+
+	// Reject makes publishing over the limit fail with ErrRateLimited
+	// instead of blocking until a token is available. Subscriber limits
+	// always block.
+	Reject bool
+}
+
+// LimiterStats is the state of a rate limiter.
+type LimiterStats struct {
+	// Name is the name of the subscription the limiter paces, empty for
+	// the publish limiter and unnamed subscriptions.
+	Name string
+
+	// Tokens is the number of tokens in the bucket. It is negative while
+	// blocked values wait for tokens that are already promised to them.
+	Tokens float64
+
+	Rate  float64
+	Burst int
+
+	// Delayed is the number of values that waited for a token.
+	Delayed uint64
+
+	// Rejected is the number of values rejected with ErrRateLimited.
+	Rejected uint64
+}
+
+type limiter struct {
+	delayed, rejected atomic.Uint64
+
+	mu     sync.Mutex
+	cfg    RateLimit
+	clock  Clock
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(cfg RateLimit, clock Clock) (*limiter, error) {
+	if cfg.Rate <= 0 || math.IsInf(cfg.Rate, 0) || math.IsNaN(cfg.Rate) {
+		return nil, errors.New("rate limit Rate must be > 0")
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+
+	return &limiter{
+		cfg:    cfg,
+		clock:  clock,
+		tokens: float64(cfg.Burst),
+		last:   clock.Now(),
+	}, nil
+}
+
+// take takes n tokens. If the bucket is short of tokens, take either waits
+// until they are available or returns ErrRateLimited.
+func (l *limiter) take(n int) error {
+	l.mu.Lock()
+	l.refill()
+
+	need := float64(n)
+	if l.tokens >= need {
+		l.tokens -= need
+		l.mu.Unlock()
+		return nil
+	}
+
+	if l.cfg.Reject {
+		l.mu.Unlock()
+		l.rejected.Add(uint64(n))
+		return ErrRateLimited
+	}
+
+	// reserve the tokens so later callers queue up behind this one.
+	d := time.Duration((need - l.tokens) / l.cfg.Rate * float64(time.Second))
+	l.tokens -= need
+	l.mu.Unlock()
+
+	l.delayed.Add(uint64(n))
+	Sleep(l.clock, d)
+	return nil
+}
+
+// pace wraps rfn to take a token before every value that is not a marker.
+func (l *limiter) pace(rfn ReaderFunc) ReaderFunc {
+	return func(v interface{}) bool {
+		if _, ok := v.(MarkerChan); !ok {
+			l.take(1)
+		}
+		return rfn(v)
+	}
+}
+
+func (l *limiter) stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	return LimiterStats{
+		Tokens:   l.tokens,
+		Rate:     l.cfg.Rate,
+		Burst:    l.cfg.Burst,
+		Delayed:  l.delayed.Load(),
+		Rejected: l.rejected.Load(),
+	}
+}
+
+// assumes mu held
+func (l *limiter) refill() {
+	now := l.clock.Now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(float64(l.cfg.Burst), l.tokens+elapsed.Seconds()*l.cfg.Rate)
+	}
+	l.last = now
+}
+
+// limiterSet tracks the limiters of active subscriptions for Stats.
+type limiterSet struct {
+	mu   sync.Mutex
+	lims map[*limiter]string
+}
+
+func (s *limiterSet) add(l *limiter, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lims == nil {
+		s.lims = make(map[*limiter]string)
+	}
+	s.lims[l] = name
+}
+
+func (s *limiterSet) remove(l *limiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lims, l)
+}
+
+func (s *limiterSet) stats() []LimiterStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.lims) == 0 {
+		return nil
+	}
+
+	ls := make([]LimiterStats, 0, len(s.lims))
+	for l, name := range s.lims {
+		st := l.stats()
+		st.Name = name
+		ls = append(ls, st)
+	}
+	sort.SliceStable(ls, func(i, j int) bool { return ls[i].Name < ls[j].Name })
+	return ls
+}
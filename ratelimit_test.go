@@ -0,0 +1,141 @@
+package pubsub
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPubRateLimitReject(t *testing.T) {
+	clock := newFakeClock()
+	ps, err := New(8, 1, WithClock(clock), WithPubRateLimit(RateLimit{
+		Rate:   1,
+		Burst:  2,
+		Reject: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"A", "B"} {
+		if err := ps.Pub(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ps.Pub("C"); err != ErrRateLimited {
+		t.Fatalf("want err %q, got %v", ErrRateLimited, err)
+	}
+	if err := ps.PubSlice([]interface{}{"C", "D"}); err != ErrRateLimited {
+		t.Fatalf("want err %q, got %v", ErrRateLimited, err)
+	}
+	ps.buffer.Write("E") // dropped, like a value from an added Publisher
+
+	clock.Advance(time.Second)
+	if err := ps.Pub("F"); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := []interface{}{"A", "B", "F"}, ps.buffer.Read(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want buffer %v, got %v", want, got)
+	}
+
+	want := &LimiterStats{Rate: 1, Burst: 2, Rejected: 4}
+	if got := ps.Stats().PubLimiter; !reflect.DeepEqual(want, got) {
+		t.Errorf("want publish limiter %+v, got %+v", want, got)
+	}
+	ps.Close()
+}
+
+func TestPubRateLimitBlock(t *testing.T) {
+	clock := newFakeClock()
+	ps, err := New(8, 1, WithClock(clock), WithPubRateLimit(RateLimit{Rate: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps.Pub("A")
+
+	donec := make(chan struct{})
+	go func() {
+		defer close(donec)
+
+		ps.Pub("B")
+	}()
+	waitPending(clock, 1)
+
+	if got := ps.Stats().PubLimiter; got.Tokens != -1 || got.Delayed != 1 {
+		t.Errorf("want 1 delayed value and -1 tokens, got %+v", got)
+	}
+
+	clock.Advance(time.Second / 2)
+	<-donec
+
+	if want, got := []interface{}{"A", "B"}, ps.buffer.Read(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want buffer %v, got %v", want, got)
+	}
+	ps.Close()
+}
+
+func TestSubRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	ps, err := New(8, 1, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 3)
+	_, err = ps.SubFunc(func(v interface{}) { ch <- v },
+		WithName("paced"),
+		WithSubRateLimit(RateLimit{Rate: 1}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps.PubSlice([]interface{}{"A", "B", "C"})
+	if v := <-ch; v != "A" {
+		t.Errorf("want value A, got %v", v)
+	}
+
+	for _, want := range []string{"B", "C"} {
+		waitPending(clock, 1)
+		if got := ps.Stats().SubLimiters; len(got) != 1 || got[0].Name != "paced" || got[0].Tokens != -1 {
+			t.Errorf("want paced limiter with -1 tokens, got %+v", got)
+		}
+
+		select {
+		case v := <-ch:
+			t.Fatalf("want value %s delayed, got %v", want, v)
+		default:
+		}
+
+		clock.Advance(time.Second)
+		if v := <-ch; v != want {
+			t.Errorf("want value %s, got %v", want, v)
+		}
+	}
+
+	ps.Close()
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	if _, err := New(8, 1, WithPubRateLimit(RateLimit{})); err == nil {
+		t.Error("want error for zero publish rate")
+	}
+
+	ps, err := New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.SubFunc(func(interface{}) {}, WithSubRateLimit(RateLimit{Rate: -1})); err == nil {
+		t.Error("want error for negative subscription rate")
+	}
+	ps.Close()
+}
+
+func waitPending(c *fakeClock, n int) {
+	for c.pending() < n {
+		runtime.Gosched()
+	}
+}
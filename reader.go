@@ -0,0 +1,345 @@
+package pubsub
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/benburkert/pubsub/cursor"
+)
+
+// Reader tracks the progress of a ReadTo reader.
+type Reader struct {
+	b *Buffer
+	c *cursor.Cursor
+
+	seq       atomic.Int64
+	skipped   atomic.Uint64
+	delivered atomic.Uint64
+	done      atomic.Bool
+	donec     chan struct{}
+
+	// paused is set while the reader is paused, resumec is closed when it
+	// is resumed.
+	pmu     sync.Mutex
+	paused  atomic.Bool
+	resumec chan struct{}
+
+	// what to do with values read while paused, nil to block.
+	pcfg    atomic.Pointer[pauseConfig]
+	dropped atomic.Uint64
+
+	// spill is set for readers whose pending values are moved to an
+	// Overflow by writers once they lag too far behind.
+	spill *spillConfig
+
+	// acks is set for readers whose position moves when values are
+	// acknowledged instead of when they are read.
+	acks *ackSet
+
+	// intr is handed to the reader before its next value, see interrupt.
+	// waiting is set while the reader waits for a write.
+	intr    atomic.Pointer[MarkerChan]
+	waiting atomic.Bool
+
+	// gid is the ID of the reading goroutine, set if stall reports include
+	// stacks.
+	gid atomic.Uint64
+}
+
+// Seq returns the sequence number of the next value the reader has not
+// finished.
+func (r *Reader) Seq() int64 {
+	return r.seq.Load()
+}
+
+// Lag returns the number of written values the reader has not read yet,
+// including values moved to its Overflow. It is zero once the reader is
+// done.
+func (r *Reader) Lag() int64 {
+	if r.done.Load() {
+		return 0
+	}
+
+	lag := r.b.Seq() - r.seq.Load()
+	if o := r.overflow(); o != nil {
+		lag += int64(o.Len())
+	}
+	return lag
+}
+
+// Skipped returns the number of expired values the reader skipped.
+func (r *Reader) Skipped() uint64 {
+	return r.skipped.Load()
+}
+
+// Delivered returns the number of values, other than markers, passed to
+// the ReaderFunc.
+func (r *Reader) Delivered() uint64 {
+	return r.delivered.Load()
+}
+
+// Dropped returns the number of values the reader discarded while paused.
+func (r *Reader) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// SetPausePolicy sets what happens to the values written while the reader
+// is paused. The Overflow is only used by PauseSpill, and is ignored if the
+// reader already spills to an Overflow when it lags. It must be called
+// before the reader is first paused.
+func (r *Reader) SetPausePolicy(p PausePolicy, o Overflow) {
+	if r.spill != nil {
+		o = r.spill.overflow
+	}
+	if p == PauseSpill && o == nil {
+		o = NewMemoryOverflow()
+	}
+	r.pcfg.Store(&pauseConfig{policy: p, overflow: o})
+}
+
+// Pause stops the reader before the next value without releasing its
+// position. By default a paused reader holds back writers once the buffer
+// is full, see SetPausePolicy for the alternatives.
+func (r *Reader) Pause() {
+	r.pmu.Lock()
+	defer r.pmu.Unlock()
+
+	if !r.paused.Load() {
+		r.resumec = make(chan struct{})
+		r.paused.Store(true)
+	}
+}
+
+// Resume continues reading after Pause.
+func (r *Reader) Resume() {
+	r.pmu.Lock()
+	defer r.pmu.Unlock()
+
+	if r.paused.Load() {
+		r.paused.Store(false)
+		close(r.resumec)
+
+		if r.undrained() {
+			// the reader may be waiting for a write.
+			go r.b.wakeReaders()
+		}
+	}
+}
+
+// interrupt hands m to the reader before the values it has not read yet,
+// once it is done with the value it is reading. Only readers started by
+// the buffer read loop, such as those of ReadTo and the subscribe methods,
+// are interrupted; a reader whose handler never returns keeps its cursor.
+// If the reader waits for a write, interrupt wakes it and waits for
+// running handlers to return, like a write.
+func (r *Reader) interrupt(m MarkerChan) {
+	r.intr.Store(&m)
+
+	if r.waiting.Load() {
+		r.b.wakeReaders()
+	}
+}
+
+func (r *Reader) interrupted() bool {
+	return r.intr.Load() != nil
+}
+
+// Paused reports whether the reader is paused.
+func (r *Reader) Paused() bool {
+	return r.paused.Load()
+}
+
+// waitResume blocks until the reader is resumed.
+func (r *Reader) waitResume() {
+	r.pmu.Lock()
+	resumec := r.resumec
+	paused := r.paused.Load()
+	r.pmu.Unlock()
+
+	if paused {
+		<-resumec
+	}
+}
+
+// policy returns the pause policy of the reader.
+func (r *Reader) policy() PausePolicy {
+	if pc := r.pcfg.Load(); pc != nil {
+		return pc.policy
+	}
+	return PauseBlock
+}
+
+// overflow returns the Overflow of the reader, or nil if it has none.
+func (r *Reader) overflow() Overflow {
+	if r.spill != nil {
+		return r.spill.overflow
+	}
+	if pc := r.pcfg.Load(); pc != nil {
+		return pc.overflow
+	}
+	return nil
+}
+
+// read hands v to rfn, or moves it out of the way if the reader is paused.
+// Markers are always handed over so the reader can see other readers
+// stop; a PubSub resumes a reader before it writes the reader's own stop
+// marker.
+func (r *Reader) read(seq int64, v interface{}, paused bool, rfn seqReaderFunc) bool {
+	if _, ok := v.(MarkerChan); ok {
+		return r.call(rfn, seq, v)
+	}
+
+	if paused {
+		pc := r.pcfg.Load()
+		if pc.policy != PauseSpill || pc.overflow.Push(seq, v) != nil {
+			r.dropped.Add(1)
+		}
+		return true
+	}
+
+	if !r.drain(rfn) {
+		return false
+	}
+	return r.call(rfn, seq, v)
+}
+
+// undrained reports whether the reader is not paused and has values in its
+// Overflow.
+func (r *Reader) undrained() bool {
+	if r.paused.Load() {
+		return false
+	}
+
+	o := r.overflow()
+	return o != nil && o.Len() > 0
+}
+
+// drain hands the values in the Overflow to rfn until it is empty or the
+// reader is paused again. A value that can not be read back is dropped.
+func (r *Reader) drain(rfn seqReaderFunc) bool {
+	o := r.overflow()
+	if o == nil {
+		return true
+	}
+
+	for !r.paused.Load() {
+		seq, v, ok, err := o.Pop()
+		if err != nil {
+			r.dropped.Add(1)
+			continue
+		}
+		if !ok {
+			return true
+		}
+
+		if !r.call(rfn, seq, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// call hands v to rfn. Readers that spill release the read lock meanwhile,
+// so that writers can move them while they are busy.
+func (r *Reader) call(rfn seqReaderFunc, seq int64, v interface{}) bool {
+	if _, ok := v.(MarkerChan); !ok {
+		r.delivered.Add(1)
+	}
+	if r.spill == nil {
+		return rfn(seq, v)
+	}
+
+	r.b.mu.RUnlock()
+	defer r.b.mu.RLock()
+
+	return rfn(seq, v)
+}
+
+// Done is closed once the reader stops reading and, for acknowledged
+// readers, every value it read is acknowledged.
+func (r *Reader) Done() <-chan struct{} {
+	return r.donec
+}
+
+// stop is called by the reading goroutine when the ReaderFunc returns false
+// for the value at seq.
+func (r *Reader) stop(seq int64) {
+	if r.acks == nil || r.acks.stop(seq) {
+		r.release()
+	}
+}
+
+// release frees the cursor of the reader.
+func (r *Reader) release() {
+	if r.spill != nil {
+		r.b.removeSpiller(r)
+	}
+	r.done.Store(true)
+	close(r.donec)
+	r.c.Reset()
+}
+
+type pauseConfig struct {
+	policy   PausePolicy
+	overflow Overflow
+}
+
+type spillConfig struct {
+	threshold int64
+	overflow  Overflow
+}
+
+// ackSet tracks the acknowledged values of a reader. The reader's position
+// is the sequence number of the first value that is not acknowledged.
+type ackSet struct {
+	mu    sync.Mutex
+	r     *Reader
+	mask  int64
+	acked []bool
+
+	stopped bool
+	stopSeq int64
+}
+
+func (a *ackSet) init(r *Reader, size int) {
+	r.acks = a
+	a.r = r
+	a.mask = int64(size - 1)
+	a.acked = make([]bool, size)
+}
+
+// ack marks the value at seq as finished. It returns true if the reader's
+// cursor moved, in which case writers may be waiting for it.
+func (a *ackSet) ack(seq int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.acked[seq&a.mask] = true
+
+	next := a.r.seq.Load()
+	if !a.acked[next&a.mask] {
+		return false
+	}
+
+	for a.acked[next&a.mask] {
+		a.acked[next&a.mask] = false
+		a.r.c.Inc()
+		next++
+	}
+	a.r.seq.Store(next)
+
+	if a.stopped && next == a.stopSeq {
+		a.r.release()
+	}
+	return true
+}
+
+// stop records that the reader stopped at seq. It returns true if every
+// value before seq is already acknowledged.
+func (a *ackSet) stop(seq int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopped, a.stopSeq = true, seq
+	return a.r.seq.Load() == seq
+}
@@ -0,0 +1,228 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+
+	"github.com/benburkert/pubsub"
+)
+
+// Client connects a local PubSub to a PubSub served by a Server.
+type Client struct {
+	c    PubSubClient
+	opts *options
+}
+
+// NewClient returns a Client for the Server on cc.
+func NewClient(cc grpc.ClientConnInterface, opts ...Option) *Client {
+	return &Client{
+		c:    NewPubSubClient(cc),
+		opts: newOptions(opts),
+	}
+}
+
+// Ack stores the position after seq for a named subscription.
+func (c *Client) Ack(ctx context.Context, topic, name string, seq int64) error {
+	_, err := c.c.Ack(ctx, &AckRequest{Topic: topic, Name: name, Seq: seq})
+	return err
+}
+
+// Reader returns a Publisher that publishes the values of a remote
+// subscription to a local PubSub.
+func (c *Client) Reader(req *SubscribeRequest) *Reader {
+	r := &Reader{
+		c:     c,
+		req:   req,
+		donec: make(chan struct{}),
+	}
+	r.seq.Store(-1)
+	return r
+}
+
+// Writer returns a Subscriber that publishes the values of a local PubSub
+// to a remote topic.
+func (c *Client) Writer(topic string) *Writer {
+	return &Writer{
+		c:     c,
+		topic: topic,
+		donec: make(chan struct{}),
+	}
+}
+
+// Reader is a Publisher that publishes the values of a remote
+// subscription.
+type Reader struct {
+	c   *Client
+	req *SubscribeRequest
+	seq atomic.Int64 // sequence number of the last value published, or -1
+
+	donec chan struct{}
+	err   error
+}
+
+// PublishTo starts the remote subscription and returns once it is active.
+// Publishing stops when the stream fails or ends, or when the local PubSub
+// is closed.
+func (r *Reader) PublishTo(ctx *pubsub.Context) error {
+	sctx, cancel := context.WithCancel(context.Background())
+	stream, err := r.c.c.Subscribe(sctx, r.req)
+	if err == nil {
+		err = waitActive(stream)
+	}
+	if err != nil {
+		cancel()
+		close(r.donec)
+		ctx.Close()
+		return err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done:
+			cancel()
+		case <-r.donec:
+		}
+	}()
+
+	go func() {
+		defer close(r.donec)
+		defer ctx.Close()
+		defer cancel()
+
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				if err != io.EOF && sctx.Err() == nil {
+					r.err = err
+				}
+				return
+			}
+
+			v, err := r.c.opts.decode(msg.Type, msg.Data)
+			if err != nil {
+				r.err = err
+				return
+			}
+			ctx.Buffer.Write(v)
+			r.seq.Store(msg.Seq)
+		}
+	}()
+	return nil
+}
+
+// waitActive waits for the headers the server sends once the subscription
+// is active. A stream that fails first ends without headers, Recv returns
+// its status.
+func waitActive(stream grpc.ServerStreamingClient[Message]) error {
+	md, err := stream.Header()
+	if err != nil {
+		return err
+	}
+	if md == nil {
+		_, err := stream.Recv()
+		return err
+	}
+	return nil
+}
+
+// Seq returns the remote sequence number of the last value published, or -1
+// if there is none.
+func (r *Reader) Seq() int64 {
+	return r.seq.Load()
+}
+
+// Ack stores the position after the last value published for the named
+// subscription of the Reader.
+func (r *Reader) Ack(ctx context.Context) error {
+	seq := r.Seq()
+	if seq < 0 {
+		return nil
+	}
+	return r.c.Ack(ctx, r.req.Topic, r.req.Name, seq)
+}
+
+// Done is closed once the Reader stops publishing.
+func (r *Reader) Done() <-chan struct{} {
+	return r.donec
+}
+
+// Err waits for the Reader to stop and returns the error that stopped it,
+// or nil if the remote PubSub or the local PubSub was closed.
+func (r *Reader) Err() error {
+	<-r.donec
+	return r.err
+}
+
+// Writer is a Subscriber that publishes every value to a remote topic.
+type Writer struct {
+	c     *Client
+	topic string
+
+	donec chan struct{}
+	resp  *PublishResponse
+	err   error
+}
+
+// SubscribeTo opens the publish stream and starts sending values read from
+// ctx. It stops on the first send error, when it is unsubscribed or when
+// the PubSub is closed.
+func (w *Writer) SubscribeTo(ctx *pubsub.Context) error {
+	stream, err := w.c.c.Publish(context.Background())
+	if err != nil {
+		return err
+	}
+
+	rfn := func(v interface{}) bool {
+		if _, ok := v.(pubsub.MarkerChan); ok {
+			if v != ctx.Done && v != ctx.Unsub {
+				return true
+			}
+			w.resp, w.err = stream.CloseAndRecv()
+		} else if w.err = w.send(stream, v); w.err == nil {
+			return true
+		} else if w.err == io.EOF {
+			// the server ended the stream, its status has the cause.
+			_, w.err = stream.CloseAndRecv()
+		}
+
+		close(w.donec)
+		ctx.Close()
+		return false
+	}
+
+	ctx.Track(ctx.Buffer.ReadTo(rfn))
+	return nil
+}
+
+func (w *Writer) send(stream grpc.ClientStreamingClient[PublishRequest, PublishResponse], v interface{}) error {
+	name, data, err := w.c.opts.encode(v)
+	if err != nil {
+		return err
+	}
+	return stream.Send(&PublishRequest{Topic: w.topic, Type: name, Data: data})
+}
+
+// Done is closed once the Writer stops publishing.
+func (w *Writer) Done() <-chan struct{} {
+	return w.donec
+}
+
+// Err waits for the Writer to stop and returns the error that stopped it,
+// or nil if the PubSub was closed.
+func (w *Writer) Err() error {
+	<-w.donec
+	return w.err
+}
+
+// Published waits for the Writer to stop and returns the number of values
+// the server published and rejected.
+func (w *Writer) Published() (published, rejected uint64) {
+	<-w.donec
+	if w.resp == nil {
+		return 0, 0
+	}
+	return w.resp.Published, w.resp.Rejected
+}
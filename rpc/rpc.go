@@ -0,0 +1,117 @@
+// Package rpc serves PubSub topics over gRPC, so that programs in other
+// languages can publish and subscribe, and connects a local PubSub to a
+// remote one.
+//
+// Values cross the wire as the registered type name of their Go type and
+// their encoding by a codec. The service is defined in pubsub.proto.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pubsub.proto
+
+import (
+	"errors"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+)
+
+var errNoTopic = errors.New("rpc: no such topic")
+
+// Broker finds the PubSub of a topic.
+type Broker interface {
+	Topic(name string) (*pubsub.PubSub, error)
+}
+
+// BrokerFunc is a Broker function.
+type BrokerFunc func(name string) (*pubsub.PubSub, error)
+
+// Topic calls fn.
+func (fn BrokerFunc) Topic(name string) (*pubsub.PubSub, error) {
+	return fn(name)
+}
+
+// Single returns a Broker with a single "" topic served by ps.
+func Single(ps *pubsub.PubSub) Broker {
+	return BrokerFunc(func(name string) (*pubsub.PubSub, error) {
+		if name != "" {
+			return nil, errNoTopic
+		}
+		return ps, nil
+	})
+}
+
+// Option configures a Server or a Client.
+type Option func(*options)
+
+type options struct {
+	typed   *codec.Typed
+	offsets pubsub.OffsetStore
+	queue   int
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		typed: codec.NewTyped(codec.JSON),
+		queue: 64,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.offsets == nil {
+		o.offsets = pubsub.NewMemoryOffsetStore()
+	}
+	return o
+}
+
+// WithCodec sets the codec of values. It defaults to codec.JSON.
+func WithCodec(c codec.Codec) Option {
+	return func(o *options) {
+		o.typed.Codec = c
+	}
+}
+
+// WithRegistry sets the registry of value type names. It defaults to
+// codec.DefaultRegistry.
+func WithRegistry(r *codec.Registry) Option {
+	return func(o *options) {
+		o.typed.Registry = r
+	}
+}
+
+// WithOffsetStore sets where a Server stores the position of named
+// subscriptions. It defaults to an in-memory store.
+func WithOffsetStore(s pubsub.OffsetStore) Option {
+	return func(o *options) {
+		o.offsets = s
+	}
+}
+
+// WithQueue sets the number of values a Server queues for each
+// subscription before it holds back publishers. It defaults to 64.
+func WithQueue(n int) Option {
+	return func(o *options) {
+		o.queue = n
+	}
+}
+
+// encode returns the type name and encoding of v. Values of unregistered
+// types are encoded without a name.
+func (o *options) encode(v interface{}) (string, []byte, error) {
+	name, _ := o.typed.Registry.Name(v)
+	data, err := o.typed.Codec.Marshal(v)
+	return name, data, err
+}
+
+// decode returns the value encoded with the type name. Values without a
+// name are decoded into a generic value.
+func (o *options) decode(name string, data []byte) (interface{}, error) {
+	if name != "" {
+		return o.typed.DecodeName(name, data)
+	}
+
+	var v interface{}
+	if err := o.typed.Codec.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
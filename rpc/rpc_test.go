@@ -0,0 +1,223 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+)
+
+type point struct {
+	X, Y int
+}
+
+func init() {
+	codec.RegisterType(point{})
+}
+
+// serve starts a Server for b over an in-memory connection and returns a
+// Client connected to it.
+func serve(t *testing.T, b Broker, opts ...Option) *Client {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	RegisterPubSubServer(srv, NewServer(b, opts...))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return NewClient(cc, opts...)
+}
+
+func TestWriterReader(t *testing.T) {
+	remote, err := pubsub.New(8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	c := serve(t, Single(remote))
+
+	// local src -> remote -> local dst
+	dst, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	gotc := make(chan interface{}, 4)
+	if _, err := dst.SubFunc(func(v interface{}) { gotc <- v }); err != nil {
+		t.Fatal(err)
+	}
+
+	r := c.Reader(&SubscribeRequest{})
+	if err := dst.AddPublisher(r); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := c.Writer("")
+	if _, err := src.AddSubscriber(w); err != nil {
+		t.Fatal(err)
+	}
+
+	src.Pub(point{1, 2})
+	src.Pub("two")
+	src.Close()
+
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if published, rejected := w.Published(); published != 2 || rejected != 0 {
+		t.Errorf("want 2 published, 0 rejected, got %d, %d", published, rejected)
+	}
+
+	for _, want := range []interface{}{point{1, 2}, "two"} {
+		if got := <-gotc; got != want {
+			t.Errorf("want value %#v, got %#v", want, got)
+		}
+	}
+
+	if want, got := int64(1), r.Seq(); want != got {
+		t.Errorf("want seq %d, got %d", want, got)
+	}
+}
+
+func TestSubscribeOffsetAck(t *testing.T) {
+	remote, err := pubsub.New(8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	c := serve(t, Single(remote))
+
+	for _, v := range []interface{}{"A", point{1, 1}, "B", point{2, 2}} {
+		remote.Pub(v)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	offset := int64(0)
+	stream, err := c.c.Subscribe(ctx, &SubscribeRequest{
+		Name:   "sub",
+		Offset: &offset,
+		Types:  []string{codec.TypeName(reflect.TypeOf(point{}))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []int64{1, 3} {
+		msg, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Seq != want {
+			t.Errorf("want seq %d, got %d", want, msg.Seq)
+		}
+	}
+
+	// a second subscription with the name is rejected while it is active.
+	dup, err := c.c.Subscribe(ctx, &SubscribeRequest{Name: "sub"})
+	if err == nil {
+		_, err = dup.Recv()
+	}
+	if want, got := codes.AlreadyExists, status.Code(err); want != got {
+		t.Errorf("want code %s, got %s", want, got)
+	}
+
+	if err := c.Ack(ctx, "", "sub", 1); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	// resume after the ack.
+	local, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	gotc := make(chan interface{}, 4)
+	if _, err := local.SubFunc(func(v interface{}) { gotc <- v }); err != nil {
+		t.Fatal(err)
+	}
+
+	var r *Reader
+	for {
+		r = c.Reader(&SubscribeRequest{Name: "sub"})
+		err := local.AddPublisher(r)
+		if err == nil {
+			break
+		}
+		if status.Code(err) != codes.AlreadyExists {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []interface{}{"B", point{2, 2}} {
+		if got := <-gotc; got != want {
+			t.Errorf("want value %#v, got %#v", want, got)
+		}
+	}
+
+	remote.Close()
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	remote, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	c := serve(t, Single(remote))
+
+	ctx := context.Background()
+	if want, got := codes.InvalidArgument, status.Code(c.Ack(ctx, "", "", 0)); want != got {
+		t.Errorf("want ack code %s, got %s", want, got)
+	}
+	if want, got := codes.NotFound, status.Code(c.Ack(ctx, "missing", "sub", 0)); want != got {
+		t.Errorf("want ack code %s, got %s", want, got)
+	}
+
+	local, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	w := c.Writer("")
+	if _, err := local.AddSubscriber(w); err != nil {
+		t.Fatal(err)
+	}
+	local.Pub(struct{ Unregistered chan int }{})
+
+	if err := w.Err(); err == nil {
+		t.Error("want encode error")
+	}
+}
@@ -0,0 +1,240 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/benburkert/pubsub"
+)
+
+// Server implements the PubSub service for the topics of a Broker.
+type Server struct {
+	UnimplementedPubSubServer
+
+	broker Broker
+	opts   *options
+
+	// names of the active named subscriptions, by offset key.
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+// NewServer returns a Server for the topics of b. Register it with a
+// grpc.Server with RegisterPubSubServer.
+func NewServer(b Broker, opts ...Option) *Server {
+	return &Server{
+		broker: b,
+		opts:   newOptions(opts),
+		active: make(map[string]bool),
+	}
+}
+
+// Publish publishes the values of the stream to their topic until the
+// client closes it.
+func (s *Server) Publish(stream grpc.ClientStreamingServer[PublishRequest, PublishResponse]) error {
+	resp := &PublishResponse{}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+
+		ps, err := s.topic(req.Topic)
+		if err != nil {
+			return err
+		}
+
+		v, err := s.opts.decode(req.Type, req.Data)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		switch err := ps.Pub(v); {
+		case err == nil:
+			resp.Published++
+		case errors.Is(err, pubsub.ErrRateLimited):
+			resp.Rejected++
+		default:
+			return status.Error(codes.Unavailable, err.Error())
+		}
+	}
+}
+
+// Subscribe streams the values of a topic until the client cancels the
+// stream or the PubSub is closed. Response headers are sent once the
+// subscription is active.
+func (s *Server) Subscribe(req *SubscribeRequest, stream grpc.ServerStreamingServer[Message]) error {
+	ps, err := s.topic(req.Topic)
+	if err != nil {
+		return err
+	}
+
+	seq := int64(math.MaxInt64) // clamped to the write sequence
+	if req.Name != "" {
+		key := offsetKey(req.Topic, req.Name)
+		if !s.claim(key) {
+			return status.Errorf(codes.AlreadyExists, "subscription %q is active", req.Name)
+		}
+		defer s.release(key)
+
+		stored, ok, err := s.opts.offsets.Load(key)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if ok {
+			seq = stored
+		}
+	}
+	if req.Offset != nil {
+		seq = *req.Offset
+	}
+
+	var types map[string]bool
+	if len(req.Types) > 0 {
+		types = make(map[string]bool, len(req.Types))
+		for _, name := range req.Types {
+			types[name] = true
+		}
+	}
+
+	sub := &streamSub{
+		seq:    seq,
+		events: make(chan event, s.opts.queue),
+		gone:   make(chan struct{}),
+	}
+	h, err := ps.AddSubscriber(sub)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer func() {
+		close(sub.gone)
+		h.Unsubscribe()
+	}()
+
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case ev, ok := <-sub.events:
+			if !ok {
+				return nil
+			}
+
+			name, data, err := s.opts.encode(ev.v)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			if types != nil && !types[name] {
+				continue
+			}
+
+			if err := stream.Send(&Message{Seq: ev.seq, Type: name, Data: data}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+// Ack stores the position after seq for a named subscription. The next
+// subscription with the name resumes from it.
+func (s *Server) Ack(ctx context.Context, req *AckRequest) (*AckResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "ack requires a subscription name")
+	}
+	if _, err := s.topic(req.Topic); err != nil {
+		return nil, err
+	}
+
+	if err := s.opts.offsets.Store(offsetKey(req.Topic, req.Name), req.Seq+1); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &AckResponse{}, nil
+}
+
+func (s *Server) topic(name string) (*pubsub.PubSub, error) {
+	ps, err := s.broker.Topic(name)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return ps, nil
+}
+
+func (s *Server) claim(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active[key] {
+		return false
+	}
+	s.active[key] = true
+	return true
+}
+
+func (s *Server) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.active, key)
+}
+
+// offsetKey is the OffsetStore name of a named subscription to a topic.
+func offsetKey(topic, name string) string {
+	if topic == "" {
+		return name
+	}
+	return topic + "/" + name
+}
+
+// event is a value and its sequence number.
+type event struct {
+	seq int64
+	v   interface{}
+}
+
+// streamSub is the Subscriber of a Subscribe stream. It queues values for
+// the stream, and holds back publishers while the queue is full.
+type streamSub struct {
+	seq int64
+
+	// events is closed once the subscription stops.
+	events chan event
+	gone   chan struct{}
+}
+
+func (ss *streamSub) SubscribeTo(ctx *pubsub.Context) error {
+	rfn := func(seq int64, v interface{}) bool {
+		if _, ok := v.(pubsub.MarkerChan); ok {
+			if v != ctx.Done && v != ctx.Unsub {
+				return true
+			}
+		} else {
+			select {
+			case ss.events <- event{seq: seq, v: v}:
+				return true
+			case <-ss.gone:
+			}
+		}
+
+		close(ss.events)
+		ctx.Close()
+		return false
+	}
+
+	ctx.Track(ctx.Buffer.ReadFromSeq(ss.seq, rfn))
+	return nil
+}
@@ -0,0 +1,180 @@
+package pubsub
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Scheduled is a value waiting to be published at a later time.
+type Scheduled struct {
+	v   interface{}
+	at  time.Time
+	seq uint64
+
+	s     *scheduler
+	index int
+}
+
+// At returns the time the value is due to be published.
+func (sv *Scheduled) At() time.Time {
+	return sv.at
+}
+
+// Cancel removes the value from the schedule. It returns false if the value
+// was already published or cancelled.
+func (sv *Scheduled) Cancel() bool {
+	return sv.s.cancel(sv)
+}
+
+type scheduler struct {
+	mu    sync.Mutex
+	clock Clock
+	write func(interface{})
+
+	queue  scheduleQueue
+	seq    uint64
+	timer  Timer
+	closed bool
+	firing bool // a fire is writing due values
+}
+
+func newScheduler(clock Clock, write func(interface{})) *scheduler {
+	return &scheduler{
+		clock: clock,
+		write: write,
+	}
+}
+
+func (s *scheduler) add(v interface{}, at time.Time) (*Scheduled, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, errClosed
+	}
+
+	s.seq++
+	sv := &Scheduled{
+		v:   v,
+		at:  at,
+		seq: s.seq,
+		s:   s,
+	}
+
+	heap.Push(&s.queue, sv)
+	if s.queue[0] == sv {
+		s.reset()
+	}
+	return sv, nil
+}
+
+func (s *scheduler) cancel(sv *Scheduled) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sv.index < 0 {
+		return false
+	}
+
+	head := sv.index == 0
+	heap.Remove(&s.queue, sv.index)
+	if head {
+		s.reset()
+	}
+	return true
+}
+
+func (s *scheduler) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	for _, sv := range s.queue {
+		sv.index = -1
+	}
+	s.queue = nil
+}
+
+// fire writes every due value in order. Values are written one at a time
+// without the lock, so a write blocked on a full buffer does not hold up
+// add, cancel or close. A value written while the PubSub closes lands after
+// the close marker and is discarded, like the values still pending.
+func (s *scheduler) fire() {
+	s.mu.Lock()
+	if s.firing {
+		// the running fire writes the values that came due.
+		s.mu.Unlock()
+		return
+	}
+	s.firing = true
+
+	for !s.closed && len(s.queue) > 0 && !s.queue[0].at.After(s.clock.Now()) {
+		sv := heap.Pop(&s.queue).(*Scheduled)
+		s.mu.Unlock()
+
+		s.write(sv.v)
+
+		s.mu.Lock()
+	}
+
+	s.firing = false
+	if !s.closed {
+		s.reset()
+	}
+	s.mu.Unlock()
+}
+
+// assumes s.mu Lock held
+func (s *scheduler) reset() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if len(s.queue) == 0 {
+		return
+	}
+
+	d := s.queue[0].at.Sub(s.clock.Now())
+	if d < 0 {
+		d = 0
+	}
+	s.timer = s.clock.AfterFunc(d, s.fire)
+}
+
+type scheduleQueue []*Scheduled
+
+func (q scheduleQueue) Len() int { return len(q) }
+
+func (q scheduleQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x interface{}) {
+	sv := x.(*Scheduled)
+	sv.index = len(*q)
+	*q = append(*q, sv)
+}
+
+func (q *scheduleQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	sv := old[n-1]
+	old[n-1] = nil
+	sv.index = -1
+	*q = old[:n-1]
+	return sv
+}
@@ -0,0 +1,196 @@
+package pubsub
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPubAt(t *testing.T) {
+	clock := newFakeClock()
+	ps, err := New(4, 1, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 16)
+	if _, err := ps.SubChan(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	start := clock.Now()
+	if _, err := ps.PubAt("C", start.Add(3*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.PubAfter("A", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.PubAfter("B", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := ps.PubAfter("X", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	ps.Pub("now")
+
+	clock.Advance(500 * time.Millisecond)
+	if !cancelled.Cancel() {
+		t.Error("want pending value to cancel")
+	}
+	if cancelled.Cancel() {
+		t.Error("want cancelled value to not cancel twice")
+	}
+
+	clock.Advance(5 * time.Second)
+	ps.Close()
+
+	got := []interface{}{}
+	for v := range ch {
+		got = append(got, v)
+	}
+
+	want := []interface{}{"now", "A", "B", "C"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want scheduled values %v, got %v", want, got)
+	}
+}
+
+func TestPubAtClose(t *testing.T) {
+	clock := newFakeClock()
+	ps, err := New(4, 1, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sv, err := ps.PubAfter("A", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps.Close()
+	if sv.Cancel() {
+		t.Error("want closed PubSub to discard pending values")
+	}
+	if _, err := ps.PubAfter("B", time.Second); err != errClosed {
+		t.Errorf("unexpected error %q", err)
+	}
+	if n := clock.pending(); n != 0 {
+		t.Errorf("want no pending timers, got %d", n)
+	}
+}
+
+func TestSchedulerBlockedWrite(t *testing.T) {
+	clock := newFakeClock()
+	writec, releasec := make(chan interface{}), make(chan struct{})
+	s := newScheduler(clock, func(v interface{}) {
+		writec <- v
+		<-releasec
+	})
+
+	if _, err := s.add("A", clock.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	go clock.Advance(time.Second)
+	<-writec
+
+	// the write of A is blocked, the schedule is not.
+	sv, err := s.add("B", clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sv.Cancel() {
+		t.Error("want pending value to cancel")
+	}
+	s.close()
+	close(releasec)
+
+	if _, err := s.add("C", clock.Now()); err != errClosed {
+		t.Errorf("unexpected error %q", err)
+	}
+}
+
+// fakeClock is a copy of pubsubtest.FakeClock, which can not be imported
+// by the tests of this package: pubsubtest imports pubsub.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, fn func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{c: c, at: c.now.Add(d), fn: fn}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, synchronously running every timer
+// that comes due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].at.Before(c.timers[j].at)
+		})
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		c.mu.Unlock()
+
+		t.fn()
+	}
+}
+
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	c  *fakeClock
+	at time.Time
+	fn func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	for i, ft := range t.c.timers {
+		if ft == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
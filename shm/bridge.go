@@ -0,0 +1,46 @@
+package shm
+
+import (
+	"github.com/benburkert/pubsub/bridge"
+	"github.com/benburkert/pubsub/codec"
+)
+
+// NewDecoder returns a bridge.Decoder that reads values written by an
+// Encoder from rd and decodes them with t. Use it with bridge.NewReader to
+// publish the values of a shared ring into a PubSub.
+func NewDecoder(rd *Reader, t *codec.Typed) bridge.Decoder {
+	return &decoder{rd: rd, t: t}
+}
+
+type decoder struct {
+	rd *Reader
+	t  *codec.Typed
+}
+
+func (d *decoder) Decode() (interface{}, error) {
+	b, err := d.rd.Read()
+	if err != nil {
+		return nil, err
+	}
+	return d.t.Decode(b)
+}
+
+// NewEncoder returns a bridge.Encoder that encodes values with t and writes
+// them to r. Use it with bridge.NewWriter to publish the values of a PubSub
+// to other processes.
+func NewEncoder(r *Ring, t *codec.Typed) bridge.Encoder {
+	return &encoder{r: r, t: t}
+}
+
+type encoder struct {
+	r *Ring
+	t *codec.Typed
+}
+
+func (e *encoder) Encode(v interface{}) error {
+	b, err := e.t.Encode(v)
+	if err != nil {
+		return err
+	}
+	return e.r.Write(b)
+}
@@ -0,0 +1,108 @@
+package shm
+
+import (
+	"io"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/codec"
+)
+
+// Buffer is the shared memory variant of pubsub.Buffer. It has the Write,
+// WriteSlice and ReadTo methods of a pubsub.Buffer on top of a Ring, so its
+// writers and readers can be in separate processes. A slot holds bytes, not
+// the values of one process, so values are encoded with a codec.Typed, and
+// the methods return the errors of the ring and the codec.
+type Buffer struct {
+	r *Ring
+	t *codec.Typed
+}
+
+// NewBuffer returns a Buffer that stores the values of r encoded with t.
+func NewBuffer(r *Ring, t *codec.Typed) *Buffer {
+	return &Buffer{r: r, t: t}
+}
+
+// Write writes v to the next slot. It blocks while a reader is a full lap
+// behind.
+func (b *Buffer) Write(v interface{}) error {
+	p, err := b.t.Encode(v)
+	if err != nil {
+		return err
+	}
+	return b.r.Write(p)
+}
+
+// WriteSlice writes the values of vs in order. It stops at the first error.
+func (b *Buffer) WriteSlice(vs []interface{}) error {
+	for _, v := range vs {
+		if err := b.Write(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTo calls rfn in its own goroutine with every value written after
+// ReadTo returns, until rfn returns false, the BufferReader is closed or a
+// value fails to decode.
+func (b *Buffer) ReadTo(rfn pubsub.ReaderFunc) (*BufferReader, error) {
+	rd, err := b.r.NewReader()
+	if err != nil {
+		return nil, err
+	}
+
+	br := &BufferReader{rd: rd, donec: make(chan struct{})}
+	go br.readTo(b.t, rfn)
+	return br, nil
+}
+
+// BufferReader is a reader started by Buffer.ReadTo.
+type BufferReader struct {
+	rd *Reader
+
+	donec chan struct{}
+	err   error
+}
+
+// Close stops the reader and frees its cursor. It does not wait for a rfn
+// call in progress to return.
+func (br *BufferReader) Close() error {
+	return br.rd.Close()
+}
+
+// Done is closed once the reader stops.
+func (br *BufferReader) Done() <-chan struct{} {
+	return br.donec
+}
+
+// Err waits for the reader to stop and returns the decode error that
+// stopped it, or nil.
+func (br *BufferReader) Err() error {
+	<-br.donec
+	return br.err
+}
+
+func (br *BufferReader) readTo(t *codec.Typed, rfn pubsub.ReaderFunc) {
+	defer close(br.donec)
+	defer br.rd.Close()
+
+	for {
+		p, err := br.rd.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			br.err = err
+			return
+		}
+
+		v, err := t.Decode(p)
+		if err != nil {
+			br.err = err
+			return
+		}
+		if !rfn(v) {
+			return
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package shm
+
+import "os"
+
+const supported = false
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errUnsupported
+}
+
+func munmap(mem []byte) error {
+	return errUnsupported
+}
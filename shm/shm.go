@@ -0,0 +1,374 @@
+// Package shm implements a ring buffer in a memory mapped file that
+// publishers and subscribers in separate processes on the same host can
+// share.
+//
+// The ring holds fixed size byte slots. Its header holds the write cursor
+// and the reader cursors, laid out as padded cursor.Cursor values, so the
+// processes coordinate with atomic operations on the mapped memory alone.
+// Writers claim a sequence number from the write cursor, wait for every
+// reader to be less than a lap behind it, fill the slot and stamp it with
+// the sequence number. Readers poll the stamp of their next slot. A process
+// that exits without closing its Reader holds back writers until the ring
+// is recreated.
+//
+// A Buffer is the shared memory variant of pubsub.Buffer: it writes and
+// reads values encoded into the slots of a Ring. NewEncoder and NewDecoder
+// connect a Ring to the bridge package instead, to mirror a PubSub.
+package shm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/benburkert/pubsub/cursor"
+)
+
+// Dir is the directory of shared memory files on Linux.
+const Dir = "/dev/shm"
+
+const (
+	magic   = 0x70756273756273 // "pubsubs"
+	version = 1
+
+	// alignment of the cursors and slots in the mapped file.
+	align = 128
+
+	slotHeaderSize = 16
+)
+
+var (
+	errUnsupported = errors.New("shm: shared memory is not supported on this platform")
+	errTooLarge    = errors.New("shm: value is larger than the slot size")
+	errMaxReaders  = errors.New("shm: every reader cursor is in use")
+	errHeader      = errors.New("shm: file is not a ring buffer")
+)
+
+// PollInterval is the longest time a blocked reader or writer sleeps
+// between checks of the ring.
+var PollInterval = time.Millisecond
+
+// header is stored at the start of the file.
+type header struct {
+	magic      uint64
+	version    uint64
+	slots      uint64
+	slotSize   uint64
+	readers    uint64
+	cursorSize uint64
+}
+
+// slotHeader precedes the data of every slot.
+type slotHeader struct {
+	stamp atomic.Int64 // sequence number + 1 of the last written value
+	n     uint32
+	_     uint32
+}
+
+// Ring is a mapping of a shared ring buffer file.
+type Ring struct {
+	mem []byte
+	hdr *header
+
+	wcursor  *cursor.Cursor
+	rcursors cursor.Slice
+
+	slots    int64
+	slotSize int
+	stride   int
+	data     int // offset of the first slot
+}
+
+// Path returns the path of the shared memory file called name.
+func Path(name string) string {
+	return filepath.Join(Dir, name)
+}
+
+// Create creates the ring buffer file at path, usually a Path, and maps
+// it. The ring holds at least minSlots slots of slotSize bytes and up to
+// maxReaders readers. An existing file is replaced.
+func Create(path string, minSlots, slotSize, maxReaders int) (*Ring, error) {
+	if !supported {
+		return nil, errUnsupported
+	}
+	if minSlots < 2 || slotSize < 1 || maxReaders < 1 {
+		return nil, errors.New("shm: minSlots must be > 1, slotSize and maxReaders > 0")
+	}
+
+	slots := 1
+	for slots < minSlots {
+		slots <<= 1
+	}
+
+	l := newLayout(slots, slotSize, maxReaders)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(int64(l.size)); err != nil {
+		return nil, err
+	}
+
+	mem, err := mmap(f, l.size)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRing(mem, l)
+	r.wcursor.Init(0, slots-1)
+	for _, c := range r.rcursors {
+		c.Init(-1, slots-1)
+	}
+
+	*r.hdr = header{
+		version:    version,
+		slots:      uint64(slots),
+		slotSize:   uint64(slotSize),
+		readers:    uint64(maxReaders),
+		cursorSize: uint64(unsafe.Sizeof(cursor.Cursor{})),
+	}
+	// the magic is written last so Open never sees a partial header.
+	atomic.StoreUint64(&r.hdr.magic, magic)
+	return r, nil
+}
+
+// Open maps the ring buffer file at path created by Create.
+func Open(path string) (*Ring, error) {
+	if !supported {
+		return nil, errUnsupported
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var buf [unsafe.Sizeof(header{})]byte
+	if _, err := f.ReadAt(buf[:], 0); err != nil {
+		return nil, fmt.Errorf("shm: reading header: %w", err)
+	}
+
+	hdr := (*header)(unsafe.Pointer(&buf[0]))
+	if hdr.magic != magic || hdr.version != version || hdr.cursorSize != uint64(unsafe.Sizeof(cursor.Cursor{})) {
+		return nil, errHeader
+	}
+
+	l := newLayout(int(hdr.slots), int(hdr.slotSize), int(hdr.readers))
+	if fi, err := f.Stat(); err != nil {
+		return nil, err
+	} else if fi.Size() < int64(l.size) {
+		return nil, errHeader
+	}
+
+	mem, err := mmap(f, l.size)
+	if err != nil {
+		return nil, err
+	}
+	return newRing(mem, l), nil
+}
+
+// Close unmaps the ring. Readers of the ring must be closed first.
+func (r *Ring) Close() error {
+	return munmap(r.mem)
+}
+
+// SlotSize returns the largest value the ring holds.
+func (r *Ring) SlotSize() int {
+	return r.slotSize
+}
+
+// Stats is a point in time snapshot of a Ring.
+type Stats struct {
+	Slots    int
+	SlotSize int
+
+	// WriteSeq is the sequence number of the next value written.
+	WriteSeq int64
+
+	// Readers are the sequence numbers of the next value of every open
+	// reader.
+	Readers []int64
+}
+
+// Stats returns the positions of the writers and readers of the ring.
+func (r *Ring) Stats() Stats {
+	s := Stats{
+		Slots:    int(r.slots),
+		SlotSize: r.slotSize,
+		WriteSeq: r.wcursor.Seq(),
+	}
+	for _, c := range r.rcursors {
+		if seq := c.Seq(); seq >= 0 {
+			s.Readers = append(s.Readers, seq)
+		}
+	}
+	return s
+}
+
+// Write copies p to the next slot. It blocks while a reader is a full lap
+// behind.
+func (r *Ring) Write(p []byte) error {
+	if len(p) > r.slotSize {
+		return errTooLarge
+	}
+
+	seq := r.wcursor.Inc() - 1
+	sh, data := r.slot(seq)
+
+	// wait for the previous value in the slot to be written and read.
+	poll(func() bool {
+		return sh.stamp.Load() >= seq+1-r.slots && !r.writeBarrier(seq)
+	}, nil)
+
+	copy(data, p)
+	sh.n = uint32(len(p))
+	sh.stamp.Store(seq + 1)
+	return nil
+}
+
+// NewReader allocates a reader cursor at the next value written.
+func (r *Ring) NewReader() (*Reader, error) {
+	for {
+		seq := r.wcursor.Seq()
+		c, ok := r.rcursors.TryAlloc(seq)
+		if !ok {
+			return nil, errMaxReaders
+		}
+
+		// a writer that claimed a sequence a lap ahead may have
+		// checked the readers before c was allocated.
+		if r.wcursor.Seq()-seq < r.slots {
+			return &Reader{r: r, c: c}, nil
+		}
+		c.Reset()
+	}
+}
+
+func (r *Ring) slot(seq int64) (*slotHeader, []byte) {
+	off := r.data + int(seq&(r.slots-1))*r.stride
+	sh := (*slotHeader)(unsafe.Pointer(&r.mem[off]))
+	return sh, r.mem[off+slotHeaderSize : off+slotHeaderSize+r.slotSize]
+}
+
+func (r *Ring) writeBarrier(seq int64) bool {
+	for _, c := range r.rcursors {
+		if rseq := c.Seq(); rseq >= 0 && seq-rseq >= r.slots {
+			return true
+		}
+	}
+	return false
+}
+
+// Reader reads the values of a Ring in order. A Reader is not safe for
+// concurrent use.
+type Reader struct {
+	r      *Ring
+	c      *cursor.Cursor
+	closed atomic.Bool
+}
+
+// Read returns the next value, waiting for it to be written. It returns
+// io.EOF once the Reader is closed.
+func (rd *Reader) Read() ([]byte, error) {
+	if rd.closed.Load() {
+		return nil, io.EOF
+	}
+
+	seq := rd.c.Seq()
+	sh, data := rd.r.slot(seq)
+
+	poll(func() bool {
+		return sh.stamp.Load() == seq+1
+	}, &rd.closed)
+	if rd.closed.Load() {
+		return nil, io.EOF
+	}
+
+	p := make([]byte, sh.n)
+	copy(p, data)
+	rd.c.Inc()
+	return p, nil
+}
+
+// Close frees the reader cursor. A Read blocked in another goroutine
+// returns io.EOF.
+func (rd *Reader) Close() error {
+	if rd.closed.Swap(true) {
+		return nil
+	}
+
+	rd.c.Reset()
+	return nil
+}
+
+// layout describes the offsets in a ring buffer file.
+type layout struct {
+	slots, slotSize, readers int
+	stride, data, size       int
+}
+
+func newLayout(slots, slotSize, readers int) layout {
+	csize := int(unsafe.Sizeof(cursor.Cursor{}))
+	l := layout{
+		slots:    slots,
+		slotSize: slotSize,
+		readers:  readers,
+		stride:   roundUp(slotHeaderSize+slotSize, 8),
+	}
+
+	l.data = roundUp(align+(1+readers)*csize, align)
+	l.size = l.data + slots*l.stride
+	return l
+}
+
+func newRing(mem []byte, l layout) *Ring {
+	csize := int(unsafe.Sizeof(cursor.Cursor{}))
+	r := &Ring{
+		mem:      mem,
+		hdr:      (*header)(unsafe.Pointer(&mem[0])),
+		wcursor:  (*cursor.Cursor)(unsafe.Pointer(&mem[align])),
+		rcursors: make(cursor.Slice, l.readers),
+		slots:    int64(l.slots),
+		slotSize: l.slotSize,
+		stride:   l.stride,
+		data:     l.data,
+	}
+	for i := range r.rcursors {
+		r.rcursors[i] = (*cursor.Cursor)(unsafe.Pointer(&mem[align+(1+i)*csize]))
+	}
+	return r
+}
+
+func roundUp(n, to int) int {
+	return (n + to - 1) / to * to
+}
+
+// poll waits for cond, or for stop to be set. It spins briefly before it
+// backs off to sleeping up to PollInterval.
+func poll(cond func() bool, stop *atomic.Bool) {
+	d := time.Microsecond
+	for i := 0; !cond(); i++ {
+		if stop != nil && stop.Load() {
+			return
+		}
+
+		if i < 100 {
+			runtime.Gosched()
+			continue
+		}
+
+		time.Sleep(d)
+		if d *= 2; d > PollInterval {
+			d = PollInterval
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package shm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/bridge"
+	"github.com/benburkert/pubsub/codec"
+)
+
+func create(t *testing.T, slots, slotSize, readers int) (*Ring, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ring")
+	if _, err := os.Stat(Dir); err == nil {
+		path = Path(fmt.Sprintf("pubsub-test-%d-%s", os.Getpid(), filepath.Base(t.Name())))
+		t.Cleanup(func() { os.Remove(path) })
+	}
+
+	r, err := Create(path, slots, slotSize, readers)
+	if errors.Is(err, errUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, path
+}
+
+func TestRing(t *testing.T) {
+	w, path := create(t, 4, 8, 2)
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	rd, err := r.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	const n = 1000
+	errc := make(chan error, 1)
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := w.Write([]byte(strconv.Itoa(i))); err != nil {
+				errc <- err
+				return
+			}
+		}
+		errc <- nil
+	}()
+
+	for i := 0; i < n; i++ {
+		p, err := rd.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := strconv.Itoa(i), string(p); want != got {
+			t.Fatalf("want value %q, got %q", want, got)
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRingWriteBarrier(t *testing.T) {
+	w, _ := create(t, 2, 1, 1)
+
+	rd, err := w.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := w.Write([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	donec := make(chan struct{})
+	go func() {
+		w.Write([]byte{2})
+		close(donec)
+	}()
+
+	select {
+	case <-donec:
+		t.Fatal("want write blocked by a full lap behind reader")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if p, err := rd.Read(); err != nil || p[0] != 0 {
+		t.Fatalf("want value 0, got %v, %v", p, err)
+	}
+	<-donec
+
+	rd.Close()
+	if _, err := rd.Read(); err != io.EOF {
+		t.Errorf("want error %q, got %q", io.EOF, err)
+	}
+}
+
+func TestRingStats(t *testing.T) {
+	r, _ := create(t, 4, 8, 2)
+
+	rd, err := r.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	for _, p := range []string{"A", "B", "C"} {
+		if err := r.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := rd.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Stats{Slots: 4, SlotSize: 8, WriteSeq: 3, Readers: []int64{1}}
+	if got := r.Stats(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want stats %+v, got %+v", want, got)
+	}
+}
+
+func TestRingErrors(t *testing.T) {
+	w, _ := create(t, 2, 4, 1)
+
+	if err := w.Write([]byte("large")); err != errTooLarge {
+		t.Errorf("want error %q, got %q", errTooLarge, err)
+	}
+
+	rd, err := w.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.NewReader(); err != errMaxReaders {
+		t.Errorf("want error %q, got %q", errMaxReaders, err)
+	}
+
+	rd.Close()
+	if _, err := w.NewReader(); err != nil {
+		t.Errorf("want closed reader cursor reused, got %q", err)
+	}
+
+	if _, err := Open(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("want error opening missing file")
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	w, _ := create(t, 4, 64, 2)
+	b := NewBuffer(w, codec.NewTyped(codec.JSON))
+
+	got := make(chan interface{}, 4)
+	br, err := b.ReadTo(func(v interface{}) bool {
+		got <- v
+		return v != "C"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.WriteSlice([]interface{}{"A", "B", "C", "D"}); err != nil {
+		t.Fatal(err)
+	}
+	<-br.Done()
+	if err := br.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	close(got)
+	var vs []interface{}
+	for v := range got {
+		vs = append(vs, v)
+	}
+	if want := []interface{}{"A", "B", "C"}; !reflect.DeepEqual(want, vs) {
+		t.Errorf("want values %v, got %v", want, vs)
+	}
+	if s := w.Stats(); len(s.Readers) != 0 {
+		t.Errorf("want reader cursor freed, got readers %v", s.Readers)
+	}
+
+	br, err = b.ReadTo(func(interface{}) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	br.Close()
+	<-br.Done()
+}
+
+func TestBridge(t *testing.T) {
+	w, _ := create(t, 8, 64, 1)
+	typed := codec.NewTyped(codec.JSON)
+
+	src, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := pubsub.New(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	rd, err := w.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotc := make(chan interface{}, 3)
+	if _, err := dst.SubFunc(func(v interface{}) { gotc <- v }); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := bridge.NewWriter(NewEncoder(w, typed))
+	if _, err := src.AddSubscriber(enc); err != nil {
+		t.Fatal(err)
+	}
+
+	pubErr := make(chan error, 1)
+	go func() {
+		r := bridge.NewReader(NewDecoder(rd, typed))
+		if err := dst.AddPublisher(r); err != nil {
+			pubErr <- err
+			return
+		}
+		pubErr <- r.Err()
+	}()
+
+	for _, v := range []string{"one", "two", "three"} {
+		src.Pub(v)
+	}
+	for _, want := range []string{"one", "two", "three"} {
+		if got := <-gotc; got != want {
+			t.Errorf("want value %q, got %q", want, got)
+		}
+	}
+
+	src.Close()
+	if err := enc.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	rd.Close()
+	if err := <-pubErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	w, path := create(t, 4, 16, 1)
+
+	rd, err := w.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "SHM_HELPER_RING="+path)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		p, err := rd.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := strconv.Itoa(i), string(p); want != got {
+			t.Fatalf("want value %q, got %q", want, got)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHelperProcess writes to the ring of TestProcess from a child process.
+func TestHelperProcess(t *testing.T) {
+	path := os.Getenv("SHM_HELPER_RING")
+	if path == "" {
+		t.Skip("helper process for TestProcess")
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for i := 0; i < 100; i++ {
+		if err := r.Write([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package pubsub
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/benburkert/pubsub/codec"
+)
+
+var errRestoreUsed = errors.New("Restore requires an unused PubSub")
+
+// snapshot is the wire format of a PubSub snapshot. Values are encoded with
+// the named codec, the envelope itself is a gob.
+type snapshot struct {
+	Codec   string
+	Seq     int64
+	Values  [][]byte
+	Exps    []int64
+	Offsets map[string]int64
+}
+
+// Snapshot writes the contents of the ring, the write sequence and the
+// committed position of every named subscription to w. Values are encoded with c
+// along with their type name, so their types must be registered with the
+// codec package. The ring is locked while it is captured.
+func (ps *PubSub) Snapshot(w io.Writer, c codec.Codec) error {
+	vs, exps, seq, offsets, err := ps.capture()
+	if err != nil {
+		return err
+	}
+
+	typed := codec.NewTyped(c)
+	snap := &snapshot{
+		Codec:   c.Name(),
+		Seq:     seq,
+		Values:  make([][]byte, len(vs)),
+		Exps:    exps,
+		Offsets: offsets,
+	}
+
+	for i, v := range vs {
+		data, err := typed.Encode(v)
+		if err != nil {
+			return err
+		}
+		snap.Values[i] = data
+	}
+
+	return gob.NewEncoder(w).Encode(snap)
+}
+
+// Restore loads a snapshot written by Snapshot into an unused PubSub. Named
+// subscriptions made after Restore continue from their snapshot position.
+func (ps *PubSub) Restore(r io.Reader) error {
+	snap := &snapshot{}
+	if err := gob.NewDecoder(r).Decode(snap); err != nil {
+		return err
+	}
+
+	c, ok := codec.Lookup(snap.Codec)
+	if !ok {
+		return fmt.Errorf("unknown snapshot codec %q", snap.Codec)
+	}
+	typed := codec.NewTyped(c)
+
+	vs := make([]interface{}, len(snap.Values))
+	for i, data := range snap.Values {
+		v, err := typed.Decode(data)
+		if err != nil {
+			return err
+		}
+		vs[i] = v
+	}
+
+	ps.namemu.Lock()
+	defer ps.namemu.Unlock()
+
+	if len(ps.named) > 0 {
+		return errRestoreUsed
+	}
+	if !ps.buffer.restore(snap.Seq-int64(len(vs)), vs, snap.Exps) {
+		return errRestoreUsed
+	}
+
+	for name, seq := range snap.Offsets {
+		if err := ps.offsets.Store(name, seq); err != nil {
+			return err
+		}
+		ps.named[name] = &namedSub{name: name}
+	}
+	return nil
+}
+
+// capture returns the values held by the ring and the committed positions
+// of named subscriptions. Markers are left out, so sequence numbers are
+// renumbered to count only values.
+func (ps *PubSub) capture() ([]interface{}, []int64, int64, map[string]int64, error) {
+	ps.namemu.Lock()
+	defer ps.namemu.Unlock()
+
+	// the store may be slow, load the offsets before holding back the
+	// publishers and readers of the buffer. Offsets only move forward, so
+	// an offset loaded early is still safe to resume from.
+	loaded := make(map[string]int64, len(ps.named))
+	for name := range ps.named {
+		seq, ok, err := ps.offsets.Load(name)
+		if err != nil {
+			return nil, nil, 0, nil, err
+		}
+		if ok {
+			loaded[name] = seq
+		}
+	}
+
+	b := ps.buffer
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	oseq, wseq := b.oldest(), b.wcursor.Seq()
+
+	vs := make([]interface{}, 0, wseq-oseq)
+	exps := make([]int64, 0, wseq-oseq)
+	renum := make(map[int64]int64, wseq-oseq+1)
+	for seq := oseq; seq < wseq; seq++ {
+		renum[seq] = oseq + int64(len(vs))
+
+		pos := seq & b.mask
+		if _, ok := b.data[pos].(MarkerChan); ok {
+			continue
+		}
+		vs = append(vs, b.data[pos])
+		exps = append(exps, b.exps[pos])
+	}
+	renum[wseq] = oseq + int64(len(vs))
+
+	offsets := make(map[string]int64, len(loaded))
+	for name, seq := range loaded {
+		if seq < oseq {
+			seq = oseq
+		}
+		if seq > wseq {
+			seq = wseq
+		}
+		offsets[name] = renum[seq]
+	}
+
+	return vs, exps, renum[wseq], offsets, nil
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/benburkert/pubsub/codec"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	ps, err := New(8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []interface{}{}
+	readc := make(chan struct{})
+	sub, err := ps.SubFunc(func(v interface{}) {
+		got = append(got, v)
+		if len(got) == 3 {
+			close(readc)
+		}
+	}, WithName("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.SubFunc(func(interface{}) {}, WithName("a")); err != errNameUse {
+		t.Errorf("unexpected error %q", err)
+	}
+
+	ps.PubSlice([]interface{}{1, 2, 3})
+	<-readc
+	sub.Unsubscribe()
+	<-sub.Done()
+
+	ps.PubSlice([]interface{}{4, 5})
+
+	buf := &bytes.Buffer{}
+	if err := ps.Snapshot(buf, codec.JSON); err != nil {
+		t.Fatal(err)
+	}
+	ps.Close()
+
+	if want := []interface{}{1, 2, 3}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values before snapshot %v, got %v", want, got)
+	}
+
+	ps, err = New(8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Restore(bytes.NewReader(buf.Bytes())); err != errRestoreUsed {
+		t.Errorf("unexpected error %q", err)
+	}
+
+	got = got[:0]
+	if _, err := ps.SubFunc(func(v interface{}) { got = append(got, v) }, WithName("a")); err != nil {
+		t.Fatal(err)
+	}
+	ps.Pub(6)
+	ps.Close()
+
+	if want := []interface{}{4, 5, 6}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values after restore %v, got %v", want, got)
+	}
+}
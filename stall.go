@@ -0,0 +1,175 @@
+package pubsub
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/benburkert/pubsub/cursor"
+)
+
+// StallDetector reports publishers that wait too long for a slow reader to
+// free space in the buffer.
+type StallDetector struct {
+	// Threshold is how long a publisher waits before Hook is called.
+	Threshold time.Duration
+
+	// Hook is called once for every publish that waits for longer than
+	// Threshold. It runs in its own goroutine while the publisher is
+	// still waiting.
+	Hook func(StallReport)
+
+	// Stacks adds the goroutine stack of the reader to reports. Finding it
+	// takes a stack dump of every goroutine.
+	Stacks bool
+}
+
+// StallReport describes the reader holding back a waiting publisher.
+type StallReport struct {
+	// Stuck is how long the reader has held back the publisher.
+	Stuck time.Duration
+
+	// WriteSeq is the sequence number of the value waiting to be written.
+	WriteSeq int64
+
+	// Cursor is the index of the reader cursor holding back the buffer,
+	// Seq and Pos are its sequence number and position, and Lag is the
+	// number of values it has not read.
+	Cursor int
+	Seq    int64
+	Pos    int
+	Lag    int64
+
+	// SubID is the ID of the subscription of the reader, 0 if the reader
+	// is not tracked by a Subscription. Name is empty for unnamed
+	// subscriptions.
+	SubID uint64
+	Name  string
+
+	// Stack is the goroutine stack of the reader, nil unless Stacks is set.
+	Stack []byte
+}
+
+func (ps *PubSub) reportStall(start time.Time) {
+	i, c, wseq, ok := ps.buffer.blocker()
+	if !ok {
+		return // the reader moved on
+	}
+
+	rep := StallReport{
+		Stuck:    ps.clock.Now().Sub(start),
+		WriteSeq: wseq,
+		Cursor:   i,
+		Seq:      c.Seq(),
+		Pos:      c.Pos(),
+	}
+	rep.Lag = wseq - rep.Seq
+
+	if r, id := ps.findReader(c); r != nil {
+		rep.SubID = id
+		rep.Name = ps.readerName(r)
+		if ps.stall.Stacks {
+			rep.Stack = goroutineStack(r.gid.Load())
+		}
+	}
+	ps.stall.Hook(rep)
+}
+
+// findReader returns the Reader of the subscription using cursor c, and the
+// ID of the subscription.
+func (ps *PubSub) findReader(c *cursor.Cursor) (*Reader, uint64) {
+	ps.submu.Lock()
+	defer ps.submu.Unlock()
+
+	for id, s := range ps.subs {
+		if r := s.r.Load(); r != nil && r.c == c && !r.done.Load() {
+			return r, id
+		}
+	}
+	return nil, 0
+}
+
+// readerName returns the name of the named subscription reading with r.
+func (ps *PubSub) readerName(r *Reader) string {
+	ps.namemu.Lock()
+	defer ps.namemu.Unlock()
+
+	for name, ns := range ps.named {
+		if ns.r == r && ns.active() {
+			return name
+		}
+	}
+	return ""
+}
+
+// watchStall calls onStall if the writer still waits after stallAfter. The
+// returned Timer is nil if stalls are not reported.
+func (b *Buffer) watchStall() Timer {
+	if b.onStall == nil {
+		return nil
+	}
+
+	start := b.clock.Now()
+	return b.clock.AfterFunc(b.stallAfter, func() { b.onStall(start) })
+}
+
+// blocker returns the slowest reader cursor that is a full lap behind the
+// writer, its index, and the write sequence. It only loads the cursors, so
+// it can be called without holding b.mu.
+func (b *Buffer) blocker() (int, *cursor.Cursor, int64, bool) {
+	wseq, size := b.wcursor.Seq(), int64(len(b.data))
+
+	idx, min := -1, wseq
+	for i, c := range b.rcursors {
+		if rseq := c.Seq(); rseq >= 0 && wseq-rseq >= size && rseq < min {
+			idx, min = i, rseq
+		}
+	}
+	if idx < 0 {
+		return 0, nil, wseq, false
+	}
+	return idx, b.rcursors[idx], wseq, true
+}
+
+// goroutineID returns the ID of the calling goroutine, as it appears in
+// stack dumps.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+
+	// goroutine 42 [running]:
+	f := bytes.Fields(buf[:n])
+	if len(f) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(f[1]), 10, 64)
+	return id
+}
+
+// goroutineStack returns the stack of goroutine id, or nil if it is not
+// running.
+func goroutineStack(id uint64) []byte {
+	if id == 0 {
+		return nil
+	}
+
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	prefix := []byte(fmt.Sprintf("goroutine %d [", id))
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(g, prefix) {
+			return g
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package pubsub
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStallDetector(t *testing.T) {
+	reports := make(chan StallReport, 1)
+	ps, err := New(2, 2, WithStallDetector(StallDetector{
+		Threshold: 10 * time.Millisecond,
+		Hook:      func(r StallReport) { reports <- r },
+		Stacks:    true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unblock := make(chan struct{})
+	sub, err := ps.SubFunc(func(interface{}) { <-unblock }, WithName("slow"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go ps.PubSlice([]interface{}{"A", "B", "C"})
+
+	var r StallReport
+	select {
+	case r = <-reports:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want stall report")
+	}
+	close(unblock)
+
+	if r.SubID != sub.ID() {
+		t.Errorf("want stalled sub id %d, got %d", sub.ID(), r.SubID)
+	}
+	if r.Name != "slow" {
+		t.Errorf("want stalled sub name %q, got %q", "slow", r.Name)
+	}
+	if r.Seq != 0 || r.Pos != 0 || r.WriteSeq != 2 || r.Lag != 2 {
+		t.Errorf("want seq=0 pos=0 write seq=2 lag=2, got seq=%d pos=%d write seq=%d lag=%d", r.Seq, r.Pos, r.WriteSeq, r.Lag)
+	}
+	if r.Stuck < 10*time.Millisecond {
+		t.Errorf("want stuck for at least the threshold, got %s", r.Stuck)
+	}
+	if !bytes.Contains(r.Stack, []byte("readTo")) {
+		t.Errorf("want reader stack, got %q", r.Stack)
+	}
+
+	ps.Close()
+}
+
+func TestStallDetectorNoStall(t *testing.T) {
+	ps, err := New(2, 1, WithStallDetector(StallDetector{
+		Threshold: time.Millisecond,
+		Hook:      func(r StallReport) { t.Errorf("unexpected stall report %+v", r) },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	if _, err := ps.SubFunc(func(v interface{}) {
+		if v == "C" {
+			close(done)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ps.PubSlice([]interface{}{"A", "B", "C"})
+	<-done
+	time.Sleep(10 * time.Millisecond)
+	ps.Close()
+}
@@ -0,0 +1,23 @@
+package pubsub
+
+// Stats is a point in time snapshot of PubSub counters.
+type Stats struct {
+	// Expired is the number of values dropped because their TTL elapsed
+	// before they were read.
+	Expired uint64
+
+	// Duplicates is the number of values dropped by the Dedup filter.
+	Duplicates uint64
+
+	// PubLimiter is the state of the publish rate limiter, nil if
+	// publishing is not rate limited.
+	PubLimiter *LimiterStats
+
+	// SubLimiters are the states of the rate limiters of active
+	// subscriptions, ordered by name.
+	SubLimiters []LimiterStats
+
+	// CommitErrors are the errors of the last commit of named
+	// subscriptions whose last commit failed, ordered by name.
+	CommitErrors []CommitError
+}
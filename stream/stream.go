@@ -0,0 +1,355 @@
+// Package stream connects PubSub instances into processing pipelines.
+//
+// Every stage subscribes to one or more source PubSubs and publishes to one
+// or more destination PubSubs. Stages publish from the subscriber goroutine,
+// so a full destination holds back the source and backpressure carries
+// through the chain. Closing a source closes the destinations of its stages
+// once they are done with the source.
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/benburkert/pubsub"
+)
+
+// Option configures a stage.
+type Option func(*config)
+
+type config struct {
+	clock pubsub.Clock
+}
+
+// WithClock sets the Clock used by time based stages.
+func WithClock(c pubsub.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{clock: pubsub.SystemClock}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// stage is a Subscriber that calls fn for every value and done once the
+// source closes.
+type stage struct {
+	fn   func(v interface{})
+	done func()
+}
+
+func (s *stage) SubscribeTo(ctx *pubsub.Context) error {
+	rfn := func(v interface{}) bool {
+		if _, ok := v.(pubsub.MarkerChan); ok {
+			if v != ctx.Done && v != ctx.Unsub {
+				return true
+			}
+
+			s.done()
+			ctx.Close()
+			return false
+		}
+
+		s.fn(v)
+		return true
+	}
+
+	ctx.Track(ctx.Buffer.ReadTo(rfn))
+	return nil
+}
+
+func connect(src, dst *pubsub.PubSub, fn func(interface{})) error {
+	return subscribe(src, &stage{fn: fn, done: dst.Close})
+}
+
+func subscribe(src *pubsub.PubSub, s *stage) error {
+	_, err := src.AddSubscriber(s)
+	return err
+}
+
+// Map publishes fn(v) to dst for every v published to src.
+func Map(src, dst *pubsub.PubSub, fn func(interface{}) interface{}) error {
+	return connect(src, dst, func(v interface{}) {
+		dst.Pub(fn(v))
+	})
+}
+
+// Filter publishes the values of src for which pred returns true to dst.
+func Filter(src, dst *pubsub.PubSub, pred func(interface{}) bool) error {
+	return connect(src, dst, func(v interface{}) {
+		if pred(v) {
+			dst.Pub(v)
+		}
+	})
+}
+
+// FlatMap publishes every value returned by fn(v) to dst for every v
+// published to src.
+func FlatMap(src, dst *pubsub.PubSub, fn func(interface{}) []interface{}) error {
+	return connect(src, dst, func(v interface{}) {
+		if vs := fn(v); len(vs) > 0 {
+			dst.PubSlice(vs)
+		}
+	})
+}
+
+// Batch publishes the values of src to dst in []interface{} batches of size
+// values. If maxWait is positive, a partial batch is published once its
+// first value is maxWait old. A partial batch is also published when src
+// closes.
+func Batch(src, dst *pubsub.PubSub, size int, maxWait time.Duration, opts ...Option) error {
+	cfg := newConfig(opts)
+
+	var (
+		mu    sync.Mutex
+		batch []interface{}
+		gen   int
+		timer pubsub.Timer
+	)
+
+	// assumes mu held
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+		if len(batch) > 0 {
+			dst.Pub(batch)
+			batch = nil
+			gen++
+		}
+	}
+
+	return subscribe(src, &stage{
+		fn: func(v interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			batch = append(batch, v)
+			if len(batch) >= size {
+				flush()
+			} else if len(batch) == 1 && maxWait > 0 {
+				g := gen
+				timer = cfg.clock.AfterFunc(maxWait, func() {
+					mu.Lock()
+					defer mu.Unlock()
+
+					if g == gen {
+						flush()
+					}
+				})
+			}
+		},
+		done: func() {
+			mu.Lock()
+			flush()
+			mu.Unlock()
+
+			dst.Close()
+		},
+	})
+}
+
+// Window publishes the values of src received in the last size duration to
+// dst as an []interface{} every slide duration. Windows are tumbling if
+// slide equals size and sliding if slide is shorter. Empty windows are not
+// published. The open window is published when src closes.
+func Window(src, dst *pubsub.PubSub, size, slide time.Duration, opts ...Option) error {
+	cfg := newConfig(opts)
+
+	type entry struct {
+		v  interface{}
+		at time.Time
+	}
+
+	var (
+		mu      sync.Mutex
+		entries []entry
+		timer   pubsub.Timer
+		closed  bool
+	)
+
+	// assumes mu held
+	emit := func(now time.Time) {
+		start := now.Add(-size)
+		for len(entries) > 0 && !entries[0].at.After(start) {
+			entries = entries[1:]
+		}
+		if len(entries) == 0 {
+			return
+		}
+
+		vs := make([]interface{}, len(entries))
+		for i, e := range entries {
+			vs[i] = e.v
+		}
+		dst.Pub(vs)
+	}
+
+	var tick func()
+	tick = func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if closed {
+			return
+		}
+		emit(cfg.clock.Now())
+		timer = cfg.clock.AfterFunc(slide, tick)
+	}
+
+	mu.Lock()
+	timer = cfg.clock.AfterFunc(slide, tick)
+	mu.Unlock()
+
+	err := subscribe(src, &stage{
+		fn: func(v interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			entries = append(entries, entry{v: v, at: cfg.clock.Now()})
+		},
+		done: func() {
+			mu.Lock()
+			closed = true
+			timer.Stop()
+			emit(cfg.clock.Now())
+			mu.Unlock()
+
+			dst.Close()
+		},
+	})
+	if err != nil {
+		mu.Lock()
+		closed = true
+		timer.Stop()
+		mu.Unlock()
+	}
+	return err
+}
+
+// Merge publishes the values of every src to dst. dst is closed once every
+// src is closed. If a src can not be subscribed to, the stages already
+// started are unsubscribed and dst is left open.
+func Merge(dst *pubsub.PubSub, srcs ...*pubsub.PubSub) error {
+	var (
+		mu   sync.Mutex
+		open = len(srcs)
+	)
+
+	done := func() {
+		mu.Lock()
+		open--
+		last := open == 0
+		mu.Unlock()
+
+		if last {
+			dst.Close()
+		}
+	}
+
+	subs := make([]*pubsub.Subscription, 0, len(srcs))
+	for _, src := range srcs {
+		s := &stage{
+			fn:   func(v interface{}) { dst.Pub(v) },
+			done: done,
+		}
+		sub, err := src.AddSubscriber(s)
+		if err != nil {
+			// the stopped stages leave open above 0, so dst stays
+			// open.
+			for _, sub := range subs {
+				sub.Unsubscribe()
+			}
+			return err
+		}
+		subs = append(subs, sub)
+	}
+	return nil
+}
+
+// Split publishes every value of src to dsts[fn(v)]. Values for which fn
+// returns an index out of range are dropped. Every dst is closed when src
+// closes.
+func Split(src *pubsub.PubSub, dsts []*pubsub.PubSub, fn func(interface{}) int) error {
+	return subscribe(src, &stage{
+		fn: func(v interface{}) {
+			if i := fn(v); i >= 0 && i < len(dsts) {
+				dsts[i].Pub(v)
+			}
+		},
+		done: func() {
+			for _, dst := range dsts {
+				dst.Close()
+			}
+		},
+	})
+}
+
+// Throttle publishes the values of src to dst at most once per interval.
+// Values are delayed, not dropped, so a throttled stage holds back src.
+func Throttle(src, dst *pubsub.PubSub, interval time.Duration, opts ...Option) error {
+	cfg := newConfig(opts)
+
+	var next time.Time
+	return connect(src, dst, func(v interface{}) {
+		pubsub.Sleep(cfg.clock, next.Sub(cfg.clock.Now()))
+
+		next = cfg.clock.Now().Add(interval)
+		dst.Pub(v)
+	})
+}
+
+// Debounce publishes a value of src to dst once no other value was
+// published to src for d. Values replaced by a later value within d are
+// dropped. A pending value is published when src closes.
+func Debounce(src, dst *pubsub.PubSub, d time.Duration, opts ...Option) error {
+	cfg := newConfig(opts)
+
+	var (
+		mu      sync.Mutex
+		pending interface{}
+		gen     int
+		timer   pubsub.Timer
+	)
+
+	return subscribe(src, &stage{
+		fn: func(v interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if timer != nil {
+				timer.Stop()
+			}
+
+			gen++
+			g := gen
+			pending = v
+			timer = cfg.clock.AfterFunc(d, func() {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if g == gen && timer != nil {
+					timer = nil
+					dst.Pub(pending)
+				}
+			})
+		},
+		done: func() {
+			mu.Lock()
+			if timer != nil {
+				timer.Stop()
+				timer = nil
+				dst.Pub(pending)
+			}
+			mu.Unlock()
+
+			dst.Close()
+		},
+	})
+}
@@ -0,0 +1,315 @@
+package stream
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/pubsubtest"
+)
+
+func TestPipeline(t *testing.T) {
+	src, mapped, dst := newPubSub(t), newPubSub(t), newPubSub(t)
+
+	if err := Map(src, mapped, func(v interface{}) interface{} { return v.(int) * 2 }); err != nil {
+		t.Fatal(err)
+	}
+	if err := Filter(mapped, dst, func(v interface{}) bool { return v.(int)%3 == 0 }); err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, dst)
+
+	for i := 0; i < 100; i++ {
+		src.Pub(i)
+	}
+	src.Close()
+
+	want := []interface{}{}
+	for i := 0; i < 100; i++ {
+		if i*2%3 == 0 {
+			want = append(want, i*2)
+		}
+	}
+	if !reflect.DeepEqual(want, *got) {
+		t.Errorf("want values %v, got %v", want, *got)
+	}
+	if err := dst.Pub(0); err == nil {
+		t.Error("want closed downstream PubSub")
+	}
+}
+
+func TestFlatMapBatch(t *testing.T) {
+	src, words, dst := newPubSub(t), newPubSub(t), newPubSub(t)
+
+	split := func(v interface{}) []interface{} {
+		vs := []interface{}{}
+		for _, w := range strings.Fields(v.(string)) {
+			vs = append(vs, w)
+		}
+		return vs
+	}
+	if err := FlatMap(src, words, split); err != nil {
+		t.Fatal(err)
+	}
+	if err := Batch(words, dst, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, dst)
+
+	src.Pub("a b c")
+	src.Pub("")
+	src.Pub("d e")
+	src.Close()
+
+	want := []interface{}{
+		[]interface{}{"a", "b"},
+		[]interface{}{"c", "d"},
+		[]interface{}{"e"},
+	}
+	if !reflect.DeepEqual(want, *got) {
+		t.Errorf("want batches %v, got %v", want, *got)
+	}
+}
+
+func TestBatchMaxWait(t *testing.T) {
+	clock := newFakeClock()
+	src, dst := newPubSub(t), newPubSub(t)
+
+	if err := Batch(src, dst, 10, time.Second, WithClock(clock)); err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, dst)
+
+	src.Pub("a")
+	clock.waitTimers(1)
+	clock.Advance(time.Second)
+	src.Close()
+
+	want := []interface{}{[]interface{}{"a"}}
+	if !reflect.DeepEqual(want, *got) {
+		t.Errorf("want batches %v, got %v", want, *got)
+	}
+}
+
+func TestMergeSplit(t *testing.T) {
+	a, b, merged := newPubSub(t), newPubSub(t), newPubSub(t)
+	even, odd := newPubSub(t), newPubSub(t)
+
+	if err := Merge(merged, a, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := Split(merged, []*pubsub.PubSub{even, odd}, func(v interface{}) int { return v.(int) % 2 }); err != nil {
+		t.Fatal(err)
+	}
+	evens, odds := collect(t, even), collect(t, odd)
+
+	for i := 0; i < 10; i++ {
+		a.Pub(i)
+		b.Pub(i + 10)
+	}
+	a.Close()
+	if err := merged.Pub(-1); err != nil {
+		t.Error("want merged PubSub open until every source closes")
+	}
+	b.Close()
+
+	for _, vs := range []*[]interface{}{evens, odds} {
+		sort.Slice(*vs, func(i, j int) bool { return (*vs)[i].(int) < (*vs)[j].(int) })
+	}
+	if want := []interface{}{0, 2, 4, 6, 8, 10, 12, 14, 16, 18}; !reflect.DeepEqual(want, *evens) {
+		t.Errorf("want evens %v, got %v", want, *evens)
+	}
+	if want := []interface{}{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}; !reflect.DeepEqual(want, *odds) {
+		t.Errorf("want odds %v, got %v", want, *odds)
+	}
+}
+
+func TestMergeError(t *testing.T) {
+	a, closed, merged := newPubSub(t), newPubSub(t), newPubSub(t)
+	closed.Close()
+
+	if err := Merge(merged, a, closed); err == nil {
+		t.Fatal("want error merging a closed PubSub")
+	}
+	for len(a.Subscriptions()) > 0 {
+		runtime.Gosched()
+	}
+	if err := merged.Pub(1); err != nil {
+		t.Errorf("want merged PubSub left open, got %v", err)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	clock := newFakeClock()
+	src, dst := newPubSub(t), newPubSub(t)
+
+	if err := Window(src, dst, 2*time.Second, time.Second, WithClock(clock)); err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, dst)
+
+	nows := 0
+	publish := func(v interface{}) {
+		src.Pub(v)
+		nows++
+		clock.waitNows(nows) // wait for v to be added to the window
+	}
+	advance := func() {
+		clock.Advance(time.Second)
+		nows++
+	}
+
+	clock.Advance(time.Second / 2)
+	publish("a")
+	advance() // 1s: [a]
+	publish("b")
+	advance() // 2s: [a b]
+	advance() // 3s: [b]
+	advance() // 4s: []
+	publish("c")
+	src.Close() // [c]
+
+	want := []interface{}{
+		[]interface{}{"a"},
+		[]interface{}{"a", "b"},
+		[]interface{}{"b"},
+		[]interface{}{"c"},
+	}
+	if !reflect.DeepEqual(want, *got) {
+		t.Errorf("want windows %v, got %v", want, *got)
+	}
+}
+
+func TestWindowError(t *testing.T) {
+	closed, full, dst := newPubSub(t), newPubSub(t), newPubSub(t)
+	closed.Close()
+	for i := 0; i < 4; i++ {
+		if _, err := full.SubFunc(func(interface{}) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, src := range []*pubsub.PubSub{closed, full} {
+		clock := pubsubtest.NewFakeClock(time.Unix(0, 0))
+		if err := Window(src, dst, 2*time.Second, time.Second, WithClock(clock)); err == nil {
+			t.Fatal("want error windowing a PubSub that can not be subscribed to")
+		}
+		if n := clock.Pending(); n != 0 {
+			t.Errorf("want no pending timers, got %d", n)
+		}
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	clock := newFakeClock()
+	src, dst := newPubSub(t), newPubSub(t)
+
+	if err := Debounce(src, dst, time.Second, WithClock(clock)); err != nil {
+		t.Fatal(err)
+	}
+	got := collect(t, dst)
+
+	src.Pub("a")
+	clock.waitTimers(1)
+	clock.Advance(time.Second / 2)
+	src.Pub("b")
+	clock.waitGen(2)
+	clock.Advance(time.Second)
+	src.Pub("c")
+	clock.waitGen(3)
+	src.Close()
+
+	if want := []interface{}{"b", "c"}; !reflect.DeepEqual(want, *got) {
+		t.Errorf("want values %v, got %v", want, *got)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	clock := newFakeClock()
+	src, dst := newPubSub(t), newPubSub(t)
+
+	if err := Throttle(src, dst, time.Second, WithClock(clock)); err != nil {
+		t.Fatal(err)
+	}
+
+	timec := make(chan time.Time, 3)
+	dst.SubFunc(func(interface{}) { timec <- clock.Now() })
+
+	src.PubSlice([]interface{}{"a", "b", "c"})
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			clock.waitGen(i)
+			clock.Advance(time.Second)
+		}
+		if at, want := <-timec, time.Unix(int64(i), 0); !at.Equal(want) {
+			t.Errorf("want value %d at %v, got %v", i, want, at)
+		}
+	}
+	src.Close()
+}
+
+func newPubSub(t *testing.T) *pubsub.PubSub {
+	ps, err := pubsub.New(4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ps
+}
+
+// collect subscribes to ps. The returned values are safe to read once ps is
+// closed.
+func collect(t *testing.T, ps *pubsub.PubSub) *[]interface{} {
+	vs := &[]interface{}{}
+	if _, err := ps.SubFunc(func(v interface{}) { *vs = append(*vs, v) }); err != nil {
+		t.Fatal(err)
+	}
+	return vs
+}
+
+// countingClock counts the calls to a pubsubtest.FakeClock, so tests can
+// wait for a stage to handle a value.
+type countingClock struct {
+	*pubsubtest.FakeClock
+
+	nows, gen atomic.Int64
+}
+
+func newFakeClock() *countingClock {
+	return &countingClock{FakeClock: pubsubtest.NewFakeClock(time.Unix(0, 0))}
+}
+
+func (c *countingClock) Now() time.Time {
+	c.nows.Add(1)
+	return c.FakeClock.Now()
+}
+
+func (c *countingClock) AfterFunc(d time.Duration, fn func()) pubsub.Timer {
+	c.gen.Add(1)
+	return c.FakeClock.AfterFunc(d, fn)
+}
+
+// waitTimers waits for n timers to be pending.
+func (c *countingClock) waitTimers(n int) {
+	for c.Pending() < n {
+		runtime.Gosched()
+	}
+}
+
+// waitGen waits for n timers to have been created.
+func (c *countingClock) waitGen(n int) {
+	for c.gen.Load() < int64(n) {
+		runtime.Gosched()
+	}
+}
+
+// waitNows waits for Now to have been called n times.
+func (c *countingClock) waitNows(n int) {
+	for c.nows.Load() < int64(n) {
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,400 @@
+package pubsub_test
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benburkert/pubsub"
+	"github.com/benburkert/pubsub/pubsubtest"
+)
+
+var (
+	stressSeed  = flag.Int64("stress.seed", 0, "replay the stress tests with a seed printed by a failure")
+	stressIters = flag.Int("stress.iters", 20, "number of random seeds run by each stress test")
+)
+
+// stressTimeout is how long an iteration may run before it is reported as
+// a hang.
+const stressTimeout = 30 * time.Second
+
+// item is a value published by the stress tests, unique per publisher.
+type item struct {
+	pub, n int
+}
+
+// stress runs model with random seeds, or with the seed of -stress.seed.
+// Each seed runs as a subtest that checks for leaked goroutines and reports
+// the flags that replay it.
+func stress(t *testing.T, model func(*testing.T, *rand.Rand)) {
+	seeds := []int64{*stressSeed}
+	if *stressSeed == 0 {
+		iters := *stressIters
+		if testing.Short() {
+			iters = (iters + 3) / 4
+		}
+
+		base := time.Now().UnixNano()
+		seeds = seeds[:0]
+		for i := 0; i < iters; i++ {
+			seeds = append(seeds, base+int64(i))
+		}
+	}
+
+	for _, seed := range seeds {
+		t.Run(fmt.Sprint(seed), func(t *testing.T) {
+			pubsubtest.CheckLeaks(t)
+
+			donec := make(chan struct{})
+			go func() {
+				defer close(donec)
+				model(t, rand.New(rand.NewSource(seed)))
+			}()
+
+			select {
+			case <-donec:
+			case <-time.After(stressTimeout):
+				t.Fatalf("hang, replay with -run '%s$' -stress.seed=%d\n%s", t.Name(), seed, stacks())
+			}
+			if t.Failed() {
+				t.Logf("replay with -run '%s$' -stress.seed=%d", t.Name(), seed)
+			}
+		})
+	}
+}
+
+// stacks returns the stacks of goroutines running pubsub code.
+func stacks() string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	var b strings.Builder
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(g, "github.com/benburkert/pubsub.") {
+			b.WriteString(g)
+			b.WriteString("\n\n")
+		}
+	}
+	return b.String()
+}
+
+// jitter yields or briefly sleeps to vary the interleaving of goroutines.
+func jitter(rng *rand.Rand) {
+	switch rng.Intn(8) {
+	case 0:
+		time.Sleep(time.Duration(rng.Intn(50)) * time.Microsecond)
+	case 1, 2:
+		runtime.Gosched()
+	}
+}
+
+// checkOrder checks that vs holds no value twice and the values of each
+// publisher in the order they were published.
+func checkOrder(t *testing.T, name string, vs []interface{}) {
+	t.Helper()
+
+	seen := make(map[item]bool, len(vs))
+	last := make(map[int]int)
+	for _, v := range vs {
+		it := v.(item)
+		if seen[it] {
+			t.Errorf("%s: duplicate %v", name, it)
+			return
+		}
+		seen[it] = true
+
+		if n, ok := last[it.pub]; ok && it.n <= n {
+			t.Errorf("%s: publisher %d value %d after %d", name, it.pub, it.n, n)
+			return
+		}
+		last[it.pub] = it.n
+	}
+}
+
+// checkWindow checks that vs is a contiguous run of the reference order,
+// ending with it if suffix is set.
+func checkWindow(t *testing.T, name string, vs []interface{}, pos map[item]int, total int, suffix bool) {
+	t.Helper()
+
+	for i, v := range vs {
+		p, ok := pos[v.(item)]
+		if !ok {
+			t.Errorf("%s: %v not in reference order", name, v)
+			return
+		}
+		if i > 0 && p != pos[vs[i-1].(item)]+1 {
+			t.Errorf("%s: %v at %d follows %v at %d, want no gap", name, v, p, vs[i-1], pos[vs[i-1].(item)])
+			return
+		}
+	}
+
+	if suffix && len(vs) > 0 && pos[vs[len(vs)-1].(item)] != total-1 {
+		t.Errorf("%s: last value %v at %d, want the last of %d", name, vs[len(vs)-1], pos[vs[len(vs)-1].(item)], total)
+	}
+}
+
+// TestStressPubSub drives a PubSub with random publishers, subscribers,
+// pauses, unsubscribes and closes. Every subscriber must receive a gap free
+// run of the values received by a reference subscriber that is subscribed
+// throughout.
+func TestStressPubSub(t *testing.T) {
+	stress(t, func(t *testing.T, rng *rand.Rand) {
+		var (
+			size       = 2 << rng.Intn(4)
+			npub       = 1 + rng.Intn(4)
+			perPub     = 50 + rng.Intn(200)
+			nsub       = 1 + rng.Intn(6)
+			closeEarly = rng.Intn(4) == 0
+		)
+
+		ps, err := pubsub.New(size, nsub+1)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ref := pubsubtest.NewRecorder()
+		if _, err := ps.AddSubscriber(ref); err != nil {
+			t.Error(err)
+			return
+		}
+
+		type subResult struct {
+			vs     []interface{}
+			unsub  bool
+			closed bool
+		}
+		results := make([]*subResult, nsub)
+
+		var subwg sync.WaitGroup
+		for i := range results {
+			res := &subResult{}
+			results[i] = res
+
+			srng := rand.New(rand.NewSource(rng.Int63()))
+			startAfter := srng.Intn(npub * perPub)
+			unsubAfter := -1
+			if srng.Intn(2) == 0 {
+				unsubAfter = srng.Intn(perPub)
+			}
+
+			subwg.Add(1)
+			go func() {
+				defer subwg.Done()
+
+				for ref.Len() < startAfter {
+					select {
+					case <-ref.Done():
+						return
+					default:
+						time.Sleep(10 * time.Microsecond)
+					}
+				}
+
+				var mu sync.Mutex
+				var sub *pubsub.Subscription
+				subc := make(chan struct{})
+				hrng := rand.New(rand.NewSource(srng.Int63()))
+
+				sub, err := ps.SubFunc(func(v interface{}) {
+					jitter(hrng)
+
+					mu.Lock()
+					res.vs = append(res.vs, v)
+					n := len(res.vs)
+					mu.Unlock()
+
+					if n == unsubAfter {
+						<-subc
+						sub.Unsubscribe()
+					}
+				})
+				if err != nil {
+					res.closed = true
+					return
+				}
+				close(subc)
+
+				// pause and resume while values arrive.
+				for i := srng.Intn(4); i > 0; i-- {
+					time.Sleep(time.Duration(srng.Intn(200)) * time.Microsecond)
+					sub.Pause()
+					time.Sleep(time.Duration(srng.Intn(200)) * time.Microsecond)
+					sub.Resume()
+				}
+
+				<-sub.Done()
+				mu.Lock()
+				res.unsub = unsubAfter >= 0 && len(res.vs) >= unsubAfter
+				mu.Unlock()
+			}()
+		}
+
+		var pubwg sync.WaitGroup
+		for p := 0; p < npub; p++ {
+			prng := rand.New(rand.NewSource(rng.Int63()))
+
+			pubwg.Add(1)
+			go func(p int) {
+				defer pubwg.Done()
+
+				for n := 0; n < perPub; {
+					if prng.Intn(4) == 0 {
+						batch := []interface{}{}
+						for k := 1 + prng.Intn(size); k > 0 && n < perPub; k-- {
+							batch = append(batch, item{p, n})
+							n++
+						}
+						if ps.PubSlice(batch) != nil {
+							return
+						}
+					} else {
+						if ps.Pub(item{p, n}) != nil {
+							return
+						}
+						n++
+					}
+					jitter(prng)
+				}
+			}(p)
+		}
+
+		if closeEarly {
+			time.Sleep(time.Duration(rng.Intn(500)) * time.Microsecond)
+			ps.Close()
+			pubwg.Wait()
+		} else {
+			pubwg.Wait()
+			ps.Close()
+		}
+		<-ref.Done()
+		subwg.Wait()
+
+		refvs := ref.Values()
+		checkOrder(t, "reference", refvs)
+		if !closeEarly && len(refvs) != npub*perPub {
+			t.Errorf("reference: want %d values, got %d", npub*perPub, len(refvs))
+		}
+
+		pos := make(map[item]int, len(refvs))
+		for i, v := range refvs {
+			pos[v.(item)] = i
+		}
+		for i, res := range results {
+			if res.closed {
+				continue
+			}
+
+			name := fmt.Sprintf("subscriber %d", i)
+			checkOrder(t, name, res.vs)
+			checkWindow(t, name, res.vs, pos, len(refvs), !res.unsub)
+		}
+	})
+}
+
+// TestStressBuffer drives a Buffer with random writers and readers that
+// start and stop at random points. Every reader must read a gap free run of
+// the values read by a reference reader that reads them all.
+func TestStressBuffer(t *testing.T) {
+	stress(t, func(t *testing.T, rng *rand.Rand) {
+		var (
+			size     = 2 << rng.Intn(4)
+			nwriter  = 1 + rng.Intn(4)
+			perWrite = 50 + rng.Intn(200)
+			nreader  = 1 + rng.Intn(6)
+			total    = nwriter * perWrite
+		)
+
+		const end = "end"
+		b := pubsub.NewBuffer(size, nreader+1)
+
+		read := func(vs *[]interface{}, limit int, rrng *rand.Rand) pubsub.ReaderFunc {
+			return func(v interface{}) bool {
+				if v == end {
+					return false
+				}
+				jitter(rrng)
+				*vs = append(*vs, v)
+				return len(*vs) != limit
+			}
+		}
+
+		var refvs []interface{}
+		refr := b.ReadTo(read(&refvs, -1, rand.New(rand.NewSource(rng.Int63()))))
+
+		readers := make([]*pubsub.Reader, nreader)
+		results := make([][]interface{}, nreader)
+		limits := make([]int, nreader)
+
+		var startwg sync.WaitGroup
+		for i := range readers {
+			rrng := rand.New(rand.NewSource(rng.Int63()))
+			limits[i] = -1
+			if rrng.Intn(2) == 0 {
+				limits[i] = 1 + rrng.Intn(perWrite)
+			}
+			delay := time.Duration(rrng.Intn(500)) * time.Microsecond
+
+			startwg.Add(1)
+			go func(i int) {
+				defer startwg.Done()
+
+				time.Sleep(delay)
+				readers[i] = b.ReadTo(read(&results[i], limits[i], rrng))
+			}(i)
+		}
+
+		var writewg sync.WaitGroup
+		for w := 0; w < nwriter; w++ {
+			wrng := rand.New(rand.NewSource(rng.Int63()))
+
+			writewg.Add(1)
+			go func(w int) {
+				defer writewg.Done()
+
+				for n := 0; n < perWrite; {
+					if wrng.Intn(4) == 0 {
+						batch := []interface{}{}
+						for k := 1 + wrng.Intn(2*size); k > 0 && n < perWrite; k-- {
+							batch = append(batch, item{w, n})
+							n++
+						}
+						b.WriteSlice(batch)
+					} else {
+						b.Write(item{w, n})
+						n++
+					}
+					jitter(wrng)
+				}
+			}(w)
+		}
+
+		writewg.Wait()
+		startwg.Wait()
+		b.Write(end)
+
+		<-refr.Done()
+		for _, r := range readers {
+			<-r.Done()
+		}
+
+		checkOrder(t, "reference", refvs)
+		if len(refvs) != total {
+			t.Errorf("reference: want %d values, got %d", total, len(refvs))
+		}
+
+		pos := make(map[item]int, len(refvs))
+		for i, v := range refvs {
+			pos[v.(item)] = i
+		}
+		for i, vs := range results {
+			name := fmt.Sprintf("reader %d", i)
+			checkOrder(t, name, vs)
+			checkWindow(t, name, vs, pos, total, limits[i] < 0)
+		}
+	})
+}
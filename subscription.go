@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Subscription is a subscription to a PubSub. It is returned by every
+// subscribe method.
+type Subscription struct {
+	id     uint64
+	ps     *PubSub
+	unsubc MarkerChan
+	unsubo sync.Once
+
+	// reader of the subscription, nil until it is tracked. startc is
+	// closed once the reader is tracked or the subscription has no reader.
+	r      atomic.Pointer[Reader]
+	startc chan struct{}
+	starto sync.Once
+
+	endo  sync.Once
+	donec chan struct{}
+	err   error
+}
+
+// ID returns an identifier that is unique within the PubSub.
+func (s *Subscription) ID() uint64 {
+	return s.id
+}
+
+// Unsubscribe asks the subscription to stop. The subscription stops after
+// the values published before Unsubscribe was called. It does not wait for
+// the subscription to stop, so it is safe to call from a handler; use Done
+// to wait.
+func (s *Subscription) Unsubscribe() {
+	s.unsubo.Do(func() {
+		s.Resume()
+		go s.ps.Pub(s.unsubc)
+	})
+}
+
+// UnsubscribeNow is like Unsubscribe but stops the subscription before the
+// values it has not read yet instead of after them. A subscription stuck
+// in its handler stops as soon as the handler returns, and no longer holds
+// back publishers.
+//
+// The interruption is handled by the read loop of Buffer.ReadTo and the
+// subscribe methods. A subscriber added with AddSubscriber whose handler
+// never returns keeps holding its position, and one that reads some other
+// way only stops after the values it has not read yet. Like Pub,
+// UnsubscribeNow must not be called from the handler of another
+// subscription to the same PubSub.
+func (s *Subscription) UnsubscribeNow() {
+	r := s.r.Load()
+	if r == nil {
+		s.Unsubscribe()
+		return
+	}
+
+	s.unsubo.Do(func() {})
+	s.Resume()
+	r.interrupt(s.unsubc)
+}
+
+// Name returns the name of a named subscription, or an empty string.
+func (s *Subscription) Name() string {
+	if r := s.r.Load(); r != nil {
+		return s.ps.readerName(r)
+	}
+	return ""
+}
+
+// Done returns a channel that is closed once the subscription has stopped.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.donec
+}
+
+// Err returns nil while the subscription is active or if it was
+// unsubscribed, and an error describing why it stopped otherwise.
+func (s *Subscription) Err() error {
+	select {
+	case <-s.donec:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Lag returns the number of published values the subscription has not
+// read yet.
+func (s *Subscription) Lag() int64 {
+	if r := s.r.Load(); r != nil {
+		return r.Lag()
+	}
+	return 0
+}
+
+// Delivered returns the number of values handed to the subscription.
+func (s *Subscription) Delivered() uint64 {
+	if r := s.r.Load(); r != nil {
+		return r.Delivered()
+	}
+	return 0
+}
+
+// Dropped returns the number of values discarded while the subscription was
+// paused.
+func (s *Subscription) Dropped() uint64 {
+	if r := s.r.Load(); r != nil {
+		return r.Dropped()
+	}
+	return 0
+}
+
+// Pause stops the delivery of values to the subscription without giving
+// up its position. What happens to the values published while it is paused
+// is set by WithPausePolicy.
+func (s *Subscription) Pause() {
+	if r := s.r.Load(); r != nil {
+		r.Pause()
+	}
+}
+
+// Resume continues the delivery of values after Pause.
+func (s *Subscription) Resume() {
+	if r := s.r.Load(); r != nil {
+		r.Resume()
+	}
+}
+
+// Paused reports whether the subscription is paused.
+func (s *Subscription) Paused() bool {
+	if r := s.r.Load(); r != nil {
+		return r.Paused()
+	}
+	return false
+}
+
+func (s *Subscription) track(r *Reader) {
+	s.r.Store(r)
+	s.started()
+}
+
+// started is called once the subscription is set up, after its reader is
+// tracked or without one.
+func (s *Subscription) started() {
+	s.starto.Do(func() { close(s.startc) })
+}
+
+// end is called once the subscription has stopped reading. Done is closed
+// once its Reader has also released its position, so a named subscription
+// can be made again as soon as Done is closed. The reader may stop before
+// it is tracked, so end waits for the subscription to be set up.
+func (s *Subscription) end(err error) {
+	s.endo.Do(func() {
+		s.err = err
+		s.ps.delSub(s)
+
+		go func() {
+			<-s.startc
+			if r := s.r.Load(); r != nil {
+				<-r.Done()
+			}
+			close(s.donec)
+		}()
+	})
+}
@@ -0,0 +1,289 @@
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubscription(t *testing.T) {
+	ps, err := New(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 4)
+	a, err := ps.SubChan(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ps.SubFunc(func(interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID() == b.ID() {
+		t.Errorf("want unique IDs, got %d twice", a.ID())
+	}
+
+	ps.PubSlice([]interface{}{"A", "B"})
+	<-ch
+	<-ch
+	if n := a.Delivered(); n != 2 {
+		t.Errorf("want 2 delivered values, got %d", n)
+	}
+
+	a.Unsubscribe()
+	<-a.Done()
+	if err := a.Err(); err != nil {
+		t.Errorf("want nil error after unsubscribe, got %v", err)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("want closed channel after unsubscribe")
+	}
+
+	if err := b.Err(); err != nil {
+		t.Errorf("want nil error while active, got %v", err)
+	}
+	ps.Close()
+	<-b.Done()
+	if err := b.Err(); err != errClosed {
+		t.Errorf("want err %q, got %v", errClosed, err)
+	}
+}
+
+func TestSubscriptionPause(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan interface{}, 8)
+	sub, err := ps.SubChan(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub.Pause()
+	if !sub.Paused() {
+		t.Error("want paused subscription")
+	}
+	ps.PubSlice([]interface{}{1, 2, 3, 4})
+
+	if n := sub.Lag(); n != 4 {
+		t.Errorf("want lag 4, got %d", n)
+	}
+	if !lockedWriteBarrier(ps.buffer) {
+		t.Error("want paused subscription to hold back writers")
+	}
+	if n := len(ch); n != 0 {
+		t.Errorf("want no values delivered while paused, got %d", n)
+	}
+
+	sub.Resume()
+	got := []interface{}{}
+	for len(got) < 4 {
+		got = append(got, <-ch)
+	}
+	if want := []interface{}{1, 2, 3, 4}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values %v, got %v", want, got)
+	}
+
+	// closing resumes paused subscriptions so they can read the close
+	// marker.
+	sub.Pause()
+	ps.PubSlice([]interface{}{5, 6, 7, 8})
+	ps.Close()
+	<-sub.Done()
+
+	if n := sub.Delivered(); n != 8 {
+		t.Errorf("want 8 delivered values, got %d", n)
+	}
+}
+
+type trackedSub struct {
+	vs []interface{}
+}
+
+func (s *trackedSub) SubscribeTo(ctx *Context) error {
+	rfn := func(v interface{}) bool {
+		if v == ctx.Done || v == ctx.Unsub {
+			ctx.Close()
+			return false
+		}
+		s.vs = append(s.vs, v)
+		return true
+	}
+
+	ctx.Track(ctx.Buffer.ReadTo(rfn))
+	return nil
+}
+
+func TestSubscriptionAddSubscriber(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	ts := &trackedSub{}
+	sub, err := ps.AddSubscriber(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub.Pause()
+	ps.PubSlice([]interface{}{"A", "B"})
+	if n := sub.Lag(); n != 2 {
+		t.Errorf("want lag 2, got %d", n)
+	}
+
+	sub.Unsubscribe()
+	<-sub.Done()
+
+	if want := []interface{}{"A", "B"}; !reflect.DeepEqual(want, ts.vs) {
+		t.Errorf("want values %v, got %v", want, ts.vs)
+	}
+	if n := sub.Delivered(); n != 2 {
+		t.Errorf("want 2 delivered values, got %d", n)
+	}
+	if _, err := ps.SubFunc(func(interface{}) {}); err != nil {
+		t.Errorf("want subscription slot freed, got %v", err)
+	}
+}
+
+func TestSubscriptionUnsubscribeNow(t *testing.T) {
+	ps, err := New(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started, unblock := make(chan struct{}), make(chan struct{})
+	var got []interface{}
+	sub, err := ps.SubFunc(func(v interface{}) {
+		got = append(got, v)
+		if len(got) == 1 {
+			close(started)
+		}
+		<-unblock
+	}, WithName("stuck"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := sub.Name(); name != "stuck" {
+		t.Errorf("want name %q, got %q", "stuck", name)
+	}
+
+	// the stuck subscription holds back the third value.
+	published := make(chan struct{})
+	go func() {
+		ps.PubSlice([]interface{}{"A", "B", "C"})
+		close(published)
+	}()
+
+	<-started
+	sub.UnsubscribeNow()
+	close(unblock)
+
+	<-sub.Done()
+	<-published
+	if err := sub.Err(); err != nil {
+		t.Errorf("want nil error after unsubscribe, got %v", err)
+	}
+	if want := []interface{}{"A"}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want values %v, got %v", want, got)
+	}
+	ps.Close()
+}
+
+// stopSub is a Subscriber whose reader stops on its first value, before
+// SubscribeTo tracks it.
+type stopSub struct {
+	readc chan struct{}
+}
+
+func (s *stopSub) SubscribeTo(ctx *Context) error {
+	r := ctx.Buffer.ReadTo(func(interface{}) bool {
+		ctx.Close()
+		close(s.readc)
+		return false
+	})
+	ctx.Buffer.Write("A")
+	<-s.readc
+
+	ctx.Track(r)
+	return nil
+}
+
+func TestSubscriptionEndBeforeTrack(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	sub, err := ps.AddSubscriber(&stopSub{readc: make(chan struct{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sub.Done()
+
+	c, ok := ps.buffer.rcursors.TryAlloc(0)
+	if !ok {
+		t.Fatal("want reader cursor released once Done is closed")
+	}
+	c.Reset()
+}
+
+// closeSub is a Subscriber that closes the PubSub before it starts reading.
+type closeSub struct {
+	trackedSub
+	ps *PubSub
+}
+
+func (s *closeSub) SubscribeTo(ctx *Context) error {
+	s.ps.Close()
+	return s.trackedSub.SubscribeTo(ctx)
+}
+
+func TestSubscriptionCloseInSubscribeTo(t *testing.T) {
+	ps, err := New(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := ps.AddSubscriber(&closeSub{ps: ps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sub.Done()
+
+	if err := sub.Err(); err != errClosed {
+		t.Errorf("want error %q, got %v", errClosed, err)
+	}
+}
+
+func TestPubSubPause(t *testing.T) {
+	ps, err := New(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	sub1, err := ps.SubFunc(func(interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps.Pause()
+
+	sub2, err := ps.SubFunc(func(interface{}) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ps.Paused() || !sub1.Paused() || !sub2.Paused() {
+		t.Error("want paused subscriptions")
+	}
+
+	ps.Resume()
+	if ps.Paused() || sub1.Paused() || sub2.Paused() {
+		t.Error("want resumed subscriptions")
+	}
+}